@@ -0,0 +1,363 @@
+// Package config loads the application configuration from an optional YAML
+// file, overridden by environment variables, and validates it.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"main/infra"
+	"main/password"
+	"main/ratelimit"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Environments.
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// DefaultJWTSecret is the development signing secret. It is public, so
+// production refuses to start with it.
+const DefaultJWTSecret = "=-0=-0"
+
+type Config struct {
+	Environment string `yaml:"environment" env:"APP_ENV"`
+
+	App        AppConfig         `yaml:"app"`
+	Server     ServerConfig      `yaml:"server"`
+	Health     HealthConfig      `yaml:"health"`
+	Database   DatabaseConfig    `yaml:"database"`
+	Auth       AuthConfig        `yaml:"auth"`
+	Password   PasswordConfig    `yaml:"password"`
+	Mail       MailConfig        `yaml:"mail"`
+	Wallet     WalletConfig      `yaml:"wallet"`
+	Pagination PaginationConfig  `yaml:"pagination"`
+	Janitor    JanitorConfig     `yaml:"janitor"`
+	Tracing    TracingConfig     `yaml:"tracing"`
+	RateLimits map[string]string `yaml:"rate_limits" env:"RATE_LIMITS"`
+}
+
+type AppConfig struct {
+	Name string `yaml:"name" env:"APP_NAME"`
+	// BaseURL is the front-end address used to build links in emails.
+	BaseURL string `yaml:"base_url" env:"APP_BASE_URL"`
+}
+
+type ServerConfig struct {
+	Port              string        `yaml:"port" env:"SERVER_PORT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once a shutdown signal arrives.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay keeps serving with /readyz failing for a while before
+	// shutting down, so load balancers stop routing to the instance first.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY"`
+	// TrustedProxies lists the IPs and CIDRs of proxies whose
+	// X-Forwarded-For header is believed. With none, the client IP is always
+	// the peer address, so clients cannot pick their own rate limit and
+	// lockout keys.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
+}
+
+type HealthConfig struct {
+	// CheckTimeout bounds each readiness check.
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+type DatabaseConfig struct {
+	// URL, when set, takes precedence over the discrete connection fields.
+	URL              string        `yaml:"url" env:"DATABASE_URL" secret:"true"`
+	Host             string        `yaml:"host" env:"DB_HOST"`
+	Port             string        `yaml:"port" env:"DB_PORT"`
+	User             string        `yaml:"user" env:"DB_USER"`
+	Password         string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name             string        `yaml:"name" env:"DB_NAME"`
+	SSLMode          string        `yaml:"sslmode" env:"DB_SSLMODE"`
+	MaxOpenConns     int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	ConnectAttempts  int           `yaml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS"`
+	ConnectBackoff   time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF"`
+	AutoMigrate      bool          `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+}
+
+type AuthConfig struct {
+	// JWTSecret signs tokens with HS256 unless SigningKeyFile is set.
+	JWTSecret            string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	SigningKeyFile       string        `yaml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE"`
+	VerificationKeyFiles []string      `yaml:"verification_key_files" env:"JWT_VERIFICATION_KEY_FILES"`
+	Issuer               string        `yaml:"issuer" env:"JWT_ISSUER"`
+	AccessTokenTTL       time.Duration `yaml:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL      time.Duration `yaml:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL"`
+	ChallengeTTL         time.Duration `yaml:"challenge_ttl" env:"AUTH_CHALLENGE_TTL"`
+	VerificationTTL      time.Duration `yaml:"verification_ttl" env:"AUTH_VERIFICATION_TTL"`
+	ResetCodeTTL         time.Duration `yaml:"reset_code_ttl" env:"AUTH_RESET_CODE_TTL"`
+	AccountLockout       LockoutConfig `yaml:"account_lockout"`
+	IPLockout            LockoutConfig `yaml:"ip_lockout"`
+}
+
+type LockoutConfig struct {
+	FreeAttempts int           `yaml:"free_attempts"`
+	BaseDelay    time.Duration `yaml:"base_delay"`
+	MaxDelay     time.Duration `yaml:"max_delay"`
+	ResetAfter   time.Duration `yaml:"reset_after"`
+}
+
+type PasswordConfig struct {
+	MinLength          int  `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	RequireUppercase   bool `yaml:"require_uppercase" env:"PASSWORD_REQUIRE_UPPERCASE"`
+	RequireLowercase   bool `yaml:"require_lowercase" env:"PASSWORD_REQUIRE_LOWERCASE"`
+	RequireDigit       bool `yaml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol      bool `yaml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	RejectPersonalInfo bool `yaml:"reject_personal_info" env:"PASSWORD_REJECT_PERSONAL_INFO"`
+	RejectCommon       bool `yaml:"reject_common" env:"PASSWORD_REJECT_COMMON"`
+}
+
+type MailConfig struct {
+	// Driver is one of smtp, file or memory.
+	Driver string     `yaml:"driver" env:"MAIL_DRIVER"`
+	From   string     `yaml:"from" env:"MAIL_FROM"`
+	Dir    string     `yaml:"dir" env:"MAIL_DIR"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	// Timeout bounds a whole send when the request has no earlier deadline.
+	Timeout time.Duration `yaml:"timeout" env:"SMTP_TIMEOUT"`
+}
+
+type WalletConfig struct {
+	// RequireVerifiedEmail blocks transfers and top ups from users who have
+	// not verified their email.
+	RequireVerifiedEmail bool `yaml:"require_verified_email" env:"REQUIRE_VERIFIED_EMAIL"`
+}
+
+// JanitorConfig schedules the background purge of expired rows.
+type JanitorConfig struct {
+	Interval          time.Duration `yaml:"interval" env:"JANITOR_INTERVAL"`
+	IdempotencyKeyTTL time.Duration `yaml:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout or file; file appends spans to File.
+	Exporter    string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	File        string `yaml:"file" env:"TRACING_FILE"`
+	ServiceName string `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+}
+
+type PaginationConfig struct {
+	DefaultPageSize int `yaml:"default_page_size" env:"DEFAULT_PAGE_SIZE"`
+	MaxPageSize     int `yaml:"max_page_size" env:"MAX_PAGE_SIZE"`
+}
+
+// Default returns the configuration used for anything not set in the file
+// or environment. It is suitable for local development only.
+func Default() *Config {
+	return &Config{
+		Environment: EnvDevelopment,
+		App: AppConfig{
+			Name:    "E-Wallet",
+			BaseURL: "http://localhost:3000",
+		},
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:             "localhost",
+			Port:             "5432",
+			User:             "postgres",
+			Name:             "e-wallet_db",
+			SSLMode:          "disable",
+			MaxOpenConns:     25,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			StatementTimeout: 30 * time.Second,
+			ConnectAttempts:  5,
+			ConnectBackoff:   time.Second,
+		},
+		Auth: AuthConfig{
+			JWTSecret:       DefaultJWTSecret,
+			Issuer:          "ewallet-api",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			ChallengeTTL:    5 * time.Minute,
+			VerificationTTL: 24 * time.Hour,
+			ResetCodeTTL:    15 * time.Minute,
+			AccountLockout: LockoutConfig{
+				FreeAttempts: 5,
+				BaseDelay:    30 * time.Second,
+				MaxDelay:     time.Hour,
+				ResetAfter:   24 * time.Hour,
+			},
+			IPLockout: LockoutConfig{
+				FreeAttempts: 20,
+				BaseDelay:    30 * time.Second,
+				MaxDelay:     time.Hour,
+				ResetAfter:   24 * time.Hour,
+			},
+		},
+		Password: passwordConfig(password.DefaultPolicy()),
+		Mail: MailConfig{
+			Driver: "file",
+			From:   "E-Wallet <no-reply@ewallet.local>",
+			Dir:    "mail",
+			SMTP: SMTPConfig{
+				Host:    "localhost",
+				Port:    "25",
+				Timeout: 10 * time.Second,
+			},
+		},
+		Wallet: WalletConfig{
+			RequireVerifiedEmail: true,
+		},
+		Pagination: PaginationConfig{
+			DefaultPageSize: 10,
+			MaxPageSize:     100,
+		},
+		Janitor: JanitorConfig{
+			Interval:          10 * time.Minute,
+			IdempotencyKeyTTL: 24 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			File:        "traces.jsonl",
+			ServiceName: "ewallet-api",
+		},
+		RateLimits: map[string]string{
+			"register": "5/1h",
+			"login":    "10/1m",
+			"password": "5/1h",
+			"email":    "3/1h",
+			"auth":     "30/1m",
+			"api":      "120/1m",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path
+// (skipped when path is empty), a .env file if present and finally the
+// environment, then validates it.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Environment == EnvProduction
+}
+
+// DB returns the connection pool settings.
+func (c *Config) DB() infra.DBConfig {
+	db := c.Database
+	return infra.DBConfig{
+		DSN:              db.URL,
+		Host:             db.Host,
+		Port:             db.Port,
+		User:             db.User,
+		Password:         db.Password,
+		Name:             db.Name,
+		SSLMode:          db.SSLMode,
+		MaxOpenConns:     db.MaxOpenConns,
+		MaxIdleConns:     db.MaxIdleConns,
+		ConnMaxLifetime:  db.ConnMaxLifetime,
+		ConnMaxIdleTime:  db.ConnMaxIdleTime,
+		StatementTimeout: db.StatementTimeout,
+		ConnectAttempts:  db.ConnectAttempts,
+		ConnectBackoff:   db.ConnectBackoff,
+	}
+}
+
+// PasswordPolicy returns the policy new passwords must meet.
+func (c *Config) PasswordPolicy() password.Policy {
+	p := c.Password
+	return password.Policy{
+		MinLength:          p.MinLength,
+		RequireUppercase:   p.RequireUppercase,
+		RequireLowercase:   p.RequireLowercase,
+		RequireDigit:       p.RequireDigit,
+		RequireSymbol:      p.RequireSymbol,
+		RejectPersonalInfo: p.RejectPersonalInfo,
+		RejectCommon:       p.RejectCommon,
+	}
+}
+
+func passwordConfig(p password.Policy) PasswordConfig {
+	return PasswordConfig{
+		MinLength:          p.MinLength,
+		RequireUppercase:   p.RequireUppercase,
+		RequireLowercase:   p.RequireLowercase,
+		RequireDigit:       p.RequireDigit,
+		RequireSymbol:      p.RequireSymbol,
+		RejectPersonalInfo: p.RejectPersonalInfo,
+		RejectCommon:       p.RejectCommon,
+	}
+}
+
+// RateLimitPolicies returns the rate limiting policy of each route group.
+func (c *Config) RateLimitPolicies() (map[string]ratelimit.Policy, error) {
+	policies := make(map[string]ratelimit.Policy, len(c.RateLimits))
+	for group, spec := range c.RateLimits {
+		policy, err := ratelimit.ParsePolicy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limits.%s: %w", group, err)
+		}
+		policies[group] = policy
+	}
+	return policies, nil
+}
+
+// YAML renders the configuration with secrets redacted.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides every field tagged `env:"NAME"` whose variable is set.
+// Lists are comma separated and replace the current value; maps are comma
+// separated key=value pairs merged over the current entries, so one key can
+// be changed without restating the rest.
+func applyEnv(config *Config) error {
+	return applyEnvValue(reflect.ValueOf(config).Elem())
+}
+
+func applyEnvValue(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			if value.Kind() == reflect.Struct {
+				if err := applyEnvValue(value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(value, raw); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, raw, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 30s")
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		value.SetBool(b)
+	case reflect.Slice:
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, item := range splitList(raw) {
+			items = reflect.Append(items, reflect.ValueOf(item))
+		}
+		value.Set(items)
+	case reflect.Map:
+		entries := reflect.MakeMap(value.Type())
+		for iter := value.MapRange(); iter.Next(); {
+			entries.SetMapIndex(iter.Key(), iter.Value())
+		}
+		for _, item := range splitList(raw) {
+			key, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value pairs")
+			}
+			entries.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		value.Set(entries)
+	default:
+		return fmt.Errorf("unsupported config type %s", value.Type())
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// redacted replaces secrets that are set.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration with every field tagged
+// `secret:"true"` masked, safe for printing and logging.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	redactValue(reflect.ValueOf(&copied).Elem())
+	return &copied
+}
+
+func redactValue(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		switch {
+		case value.Kind() == reflect.Struct:
+			redactValue(value)
+		case field.Tag.Get("secret") == "true" && value.Kind() == reflect.String && value.String() != "":
+			value.SetString(redacted)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestApplyEnvMergesMapsOverDefaults(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "login=5/1m, upload=2/1h")
+
+	config := Default()
+	if err := applyEnv(config); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default().RateLimits
+	want["login"] = "5/1m"
+	want["upload"] = "2/1h"
+	if len(config.RateLimits) != len(want) {
+		t.Fatalf("RateLimits = %v, want %v", config.RateLimits, want)
+	}
+	for group, spec := range want {
+		if got := config.RateLimits[group]; got != spec {
+			t.Errorf("RateLimits[%s] = %q, want %q", group, got, spec)
+		}
+	}
+}
+
+func TestApplyEnvRejectsMalformedMap(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "login")
+
+	if err := applyEnv(Default()); err == nil {
+		t.Fatal("applyEnv succeeded, want error")
+	}
+}
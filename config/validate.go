@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// minSecretLength is the shortest HS256 secret accepted in production.
+const minSecretLength = 32
+
+// Validate checks the configuration for mistakes, and in production for
+// secrets left at their development defaults. Every problem is reported.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%s must be positive", name)
+	}
+
+	switch c.Environment {
+	case EnvDevelopment, EnvStaging, EnvProduction:
+	default:
+		errs = append(errs, fmt.Errorf("environment must be %s, %s or %s, not %q",
+			EnvDevelopment, EnvStaging, EnvProduction, c.Environment))
+	}
+
+	check(c.Server.Port != "", "server.port is required")
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil,
+			"server.trusted_proxies: %q is not an IP address or CIDR", proxy)
+	}
+	positive("health.check_timeout", c.Health.CheckTimeout)
+
+	db := c.Database
+	if db.URL == "" {
+		check(db.Host != "", "database.host is required unless database.url is set")
+		check(db.Name != "", "database.name is required unless database.url is set")
+	}
+	check(db.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(db.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(db.ConnectAttempts >= 1, "database.connect_attempts must be at least 1")
+
+	auth := c.Auth
+	positive("auth.access_token_ttl", auth.AccessTokenTTL)
+	positive("auth.refresh_token_ttl", auth.RefreshTokenTTL)
+	positive("auth.challenge_ttl", auth.ChallengeTTL)
+	positive("auth.verification_ttl", auth.VerificationTTL)
+	positive("auth.reset_code_ttl", auth.ResetCodeTTL)
+	lockouts := []struct {
+		name   string
+		config LockoutConfig
+	}{
+		{"auth.account_lockout", auth.AccountLockout},
+		{"auth.ip_lockout", auth.IPLockout},
+	}
+	for _, l := range lockouts {
+		name, lockout := l.name, l.config
+		check(lockout.FreeAttempts >= 1, "%s.free_attempts must be at least 1", name)
+		positive(name+".base_delay", lockout.BaseDelay)
+		check(lockout.MaxDelay >= lockout.BaseDelay, "%s.max_delay must not be less than base_delay", name)
+		positive(name+".reset_after", lockout.ResetAfter)
+	}
+	if auth.SigningKeyFile == "" {
+		check(auth.JWTSecret != "", "auth.jwt_secret is required unless auth.signing_key_file is set")
+	}
+
+	check(c.Password.MinLength >= 1, "password.min_length must be at least 1")
+
+	switch c.Mail.Driver {
+	case "smtp":
+		positive("mail.smtp.timeout", c.Mail.SMTP.Timeout)
+	case "file", "memory":
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver must be smtp, file or memory, not %q", c.Mail.Driver))
+	}
+
+	check(c.Pagination.DefaultPageSize >= 1, "pagination.default_page_size must be at least 1")
+	check(c.Pagination.MaxPageSize >= c.Pagination.DefaultPageSize,
+		"pagination.max_page_size must not be less than default_page_size")
+
+	positive("janitor.interval", c.Janitor.Interval)
+	positive("janitor.idempotency_key_ttl", c.Janitor.IdempotencyKeyTTL)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file is required when tracing.exporter is file")
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or file, not %q", c.Tracing.Exporter))
+	}
+
+	if _, err := c.RateLimitPolicies(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.IsProduction() {
+		errs = append(errs, c.validateProductionSecrets()...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateProductionSecrets() []error {
+	var errs []error
+
+	if c.Auth.SigningKeyFile == "" {
+		switch {
+		case c.Auth.JWTSecret == DefaultJWTSecret:
+			errs = append(errs, errors.New("auth.jwt_secret must be changed from the default in production"))
+		case len(c.Auth.JWTSecret) < minSecretLength:
+			errs = append(errs, fmt.Errorf("auth.jwt_secret must be at least %d characters in production", minSecretLength))
+		}
+	}
+
+	if c.Database.URL == "" && c.Database.Password == "" {
+		errs = append(errs, errors.New("database.password must be set in production"))
+	}
+
+	if c.Mail.Driver == "memory" {
+		errs = append(errs, errors.New("mail.driver memory would drop every email in production"))
+	}
+
+	return errs
+}
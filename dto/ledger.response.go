@@ -0,0 +1,12 @@
+package dto
+
+import (
+	"main/entity"
+	"main/money"
+)
+
+type LedgerReport struct {
+	TrialBalance money.Amount                   `json:"trial_balance"`
+	Balanced     bool                           `json:"balanced"`
+	Mismatches   []entity.WalletBalanceMismatch `json:"mismatches"`
+}
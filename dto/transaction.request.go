@@ -1,8 +1,10 @@
 package dto
 
+import "main/money"
+
 type TransactionListRequest struct {
 	Page      int    `form:"page,default=1"`
-	Limit     int    `form:"limit,default=10"`
+	Limit     int    `form:"limit"`
 	Search    string `form:"s"`
 	SortBy    string `form:"sortBy"`
 	SortOrder string `form:"sort"`
@@ -16,3 +18,14 @@ type PaginationInfo struct {
 	TotalItems   int `json:"total_items"`
 	ItemsPerPage int `json:"items_per_page"`
 }
+
+type TransferRequest struct {
+	ToWalletNumber string       `json:"to_wallet_number" binding:"required"`
+	Amount         money.Amount `json:"amount"`
+	Description    string       `json:"description" binding:"max=255"`
+}
+
+type TopUpRequest struct {
+	Amount         money.Amount `json:"amount"`
+	SourceOfFundID int          `json:"source_of_fund_id" binding:"required"`
+}
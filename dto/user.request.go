@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
@@ -9,6 +11,8 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// IPAddress is the client address, filled in by the handler.
+	IPAddress string `json:"-"`
 }
 
 type ForgotPasswordRequest struct {
@@ -18,5 +22,43 @@ type ForgotPasswordRequest struct {
 type ResetPasswordRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	ResetCode   string `json:"reset_code" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
+	IPAddress   string `json:"-"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+	IPAddress       string `json:"-"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code      string `json:"code" binding:"required,len=6,numeric"`
+	IPAddress string `json:"-"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code" binding:"required_without=Code"`
+	IPAddress      string `json:"-"`
+}
+
+// UpdateProfileRequest replaces the user's profile. UpdatedAt must be the
+// value last read from GET /api/profile; the update is rejected if the
+// profile changed since.
+type UpdateProfileRequest struct {
+	Username    string    `json:"username" binding:"required,min=3,max=50"`
+	Email       string    `json:"email" binding:"required,email"`
+	DisplayName string    `json:"display_name" binding:"max=100"`
+	AvatarURL   string    `json:"avatar_url" binding:"omitempty,url,max=2048"`
+	UpdatedAt   time.Time `json:"updated_at" binding:"required"`
 }
@@ -1 +1,36 @@
 package dto
+
+import (
+	"main/entity"
+	"main/money"
+)
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// LoginResponse carries either the issued tokens or, when the account has
+// two-factor authentication enabled, a challenge token for /login/2fa.
+type LoginResponse struct {
+	*TokenResponse
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ProfileResponse struct {
+	*entity.User
+	WalletNumber string       `json:"wallet_number"`
+	Balance      money.Amount `json:"balance"`
+}
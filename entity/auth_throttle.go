@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type AuthThrottle struct {
+	Scope        string
+	Key          string
+	FailedCount  int
+	LockedUntil  *time.Time
+	LastFailedAt time.Time
+}
+
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	UserID    *int      `json:"user_id,omitempty"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	Action    string    `json:"action"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type IdempotencyKey struct {
+	UserID       int
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+}
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"main/money"
+	"time"
+)
+
+const (
+	LedgerAccountTypeAsset     = "ASSET"
+	LedgerAccountTypeLiability = "LIABILITY"
+	LedgerAccountTypeRevenue   = "REVENUE"
+	LedgerAccountTypeExpense   = "EXPENSE"
+)
+
+// LedgerAccountSystemFloat is the code of the system account, seeded by the
+// migrations, that holds the money backing every wallet.
+const LedgerAccountSystemFloat = "SYSTEM_FLOAT"
+
+type LedgerAccount struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	WalletID  *int      `json:"wallet_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JournalEntry struct {
+	ID            int       `json:"id"`
+	TransactionID *int      `json:"transaction_id,omitempty"`
+	Description   string    `json:"description"`
+	Postings      []Posting `json:"postings"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Posting moves Amount into (debit, positive) or out of (credit, negative)
+// a ledger account.
+type Posting struct {
+	ID        int          `json:"id"`
+	AccountID int          `json:"account_id"`
+	Amount    money.Amount `json:"amount"`
+}
+
+type WalletBalanceMismatch struct {
+	WalletID      int          `json:"wallet_id"`
+	WalletNumber  string       `json:"wallet_number"`
+	StoredBalance money.Amount `json:"stored_balance"`
+	LedgerBalance money.Amount `json:"ledger_balance"`
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "main/money"
+
+type SourceOfFund struct {
+	ID        int          `json:"id"`
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	MinAmount money.Amount `json:"min_amount"`
+	MaxAmount money.Amount `json:"max_amount"`
+}
@@ -1,15 +1,25 @@
 package entity
 
-import "time"
+import (
+	"main/money"
+	"time"
+)
+
+const (
+	TransactionTypeTransfer = "TRANSFER"
+	TransactionTypeTopUp    = "TOP_UP"
+)
 
 type Transaction struct {
-	ID              int       `json:"id"`
-	FromWalletID    *int      `json:"from_wallet_id,omitempty"`
-	ToWalletID      int       `json:"to_wallet_id"`
-	Amount          float64   `json:"amount"`
-	Description     string    `json:"description"`
-	SourceOfFundID  int       `json:"source_of_fund_id"`
-	TransactionType string    `json:"transaction_type"`
+	ID              int          `json:"id"`
+	FromWalletID    *int         `json:"from_wallet_id,omitempty"`
+	ToWalletID      int          `json:"to_wallet_id"`
+	Amount          money.Amount `json:"amount"`
+	Description     string       `json:"description"`
+	SourceOfFundID  *int         `json:"source_of_fund_id,omitempty"`
+	TransactionType string       `json:"transaction_type"`
+	// ChargeReference identifies the external charge behind a top up.
+	ChargeReference *string   `json:"charge_reference,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
 	// Additional fields for response
 	FromWalletNumber string `json:"from_wallet_number,omitempty"`
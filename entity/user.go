@@ -6,9 +6,15 @@ type User struct {
 	ID                  int        `json:"id"`
 	Username            string     `json:"username"`
 	Email               string     `json:"email"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
 	PasswordHash        string     `json:"-"`
 	ResetPasswordCode   *string    `json:"-"`
 	ResetPasswordExpiry *time.Time `json:"-"`
+	TOTPSecret          *string    `json:"-"`
+	TOTPEnabledAt       *time.Time `json:"-"`
+	DisplayName         *string    `json:"display_name"`
+	AvatarURL           *string    `json:"avatar_url"`
+	TokenVersion        int        `json:"-"`
 	CreatedAt           time.Time  `json:"created_at"`
 	UpdatedAt           time.Time  `json:"updated_at"`
 }
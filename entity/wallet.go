@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"main/money"
+	"time"
+)
+
+type Wallet struct {
+	ID           int          `json:"id"`
+	UserID       int          `json:"user_id"`
+	WalletNumber string       `json:"wallet_number"`
+	Balance      money.Amount `json:"balance"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	// Additional fields for response
+	OwnerName  string `json:"owner_name"`
+	OwnerEmail string `json:"owner_email"`
+}
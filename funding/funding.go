@@ -0,0 +1,68 @@
+package funding
+
+import (
+	"context"
+	"errors"
+	"main/money"
+	"sync"
+)
+
+// Codes of the sources of fund seeded by the migrations.
+const (
+	CodeBankTransfer = "BANK_TRANSFER"
+	CodeCreditCard   = "CREDIT_CARD"
+	CodeCash         = "CASH"
+	CodeReward       = "REWARD"
+)
+
+var ErrSourceUnavailable = errors.New("source of fund is unavailable")
+
+type Charge struct {
+	// Reference identifies the charge to the provider. Charging twice with
+	// the same reference must only move the money once.
+	Reference    string
+	UserID       int
+	WalletNumber string
+	Amount       money.Amount
+}
+
+// FundingSource pulls money from an external source of fund (a bank, card
+// processor, cash agent, ...) so it can be credited to a wallet.
+type FundingSource interface {
+	Code() string
+	Charge(ctx context.Context, charge Charge) error
+	// Refund reverses the charge made under reference, voiding it if it has
+	// not settled yet. Refunding a reference never charged is not an error.
+	Refund(ctx context.Context, reference string) error
+}
+
+// Registry looks up the provider for a source of fund by its code.
+type Registry struct {
+	mu      sync.RWMutex
+	sources map[string]FundingSource
+}
+
+func NewRegistry(sources ...FundingSource) *Registry {
+	r := &Registry{sources: make(map[string]FundingSource)}
+	for _, source := range sources {
+		r.Register(source)
+	}
+	return r
+}
+
+func (r *Registry) Register(source FundingSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[source.Code()] = source
+}
+
+func (r *Registry) Get(code string) (FundingSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	source, ok := r.sources[code]
+	if !ok {
+		return nil, ErrSourceUnavailable
+	}
+	return source, nil
+}
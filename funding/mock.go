@@ -0,0 +1,35 @@
+package funding
+
+import "context"
+
+// MockSource is an in-process provider that accepts every charge and
+// refund. It stands in for the real bank, card and cash integrations.
+type MockSource struct {
+	code string
+}
+
+func NewMockSource(code string) *MockSource {
+	return &MockSource{code: code}
+}
+
+// NewMockRegistry registers a MockSource for every seeded source of fund.
+func NewMockRegistry() *Registry {
+	return NewRegistry(
+		NewMockSource(CodeBankTransfer),
+		NewMockSource(CodeCreditCard),
+		NewMockSource(CodeCash),
+		NewMockSource(CodeReward),
+	)
+}
+
+func (s *MockSource) Code() string {
+	return s.code
+}
+
+func (s *MockSource) Charge(ctx context.Context, charge Charge) error {
+	return ctx.Err()
+}
+
+func (s *MockSource) Refund(ctx context.Context, reference string) error {
+	return ctx.Err()
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"main/keyring"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+	keys *keyring.Keyring
+}
+
+func NewKeyHandler(keys *keyring.Keyring) *KeyHandler {
+	return &KeyHandler{keys: keys}
+}
+
+// JWKS publishes the token verification keys for other services.
+func (h *KeyHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"main/dto"
+	"main/repository"
+	"main/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileHandler struct {
+	service usecase.ProfileService
+}
+
+func NewProfileHandler(service usecase.ProfileService) *ProfileHandler {
+	return &ProfileHandler{service: service}
+}
+
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	profile, err := h.service.GetProfile(c.Request.Context(), userID)
+	if errors.Is(err, repository.ErrUserNotFound) || errors.Is(err, repository.ErrWalletNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	profile, err := h.service.UpdateProfile(c.Request.Context(), userID, req)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound), errors.Is(err, repository.ErrWalletNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrUserModified), errors.Is(err, usecase.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrVerificationEmailFailed):
+		// The profile is saved; the user can ask for the email again
+		c.Error(err)
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"main/dto"
+	"main/repository"
 	"main/usecase"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -38,10 +41,7 @@ func (h *Handler) ListTransactions(c *gin.Context) {
 		}
 	}
 
-	// Set default values
-	if req.Limit <= 0 {
-		req.Limit = 10
-	}
+	// Set default values; the page size default comes from the service
 	if req.Page <= 0 {
 		req.Page = 1
 	}
@@ -51,9 +51,34 @@ func (h *Handler) ListTransactions(c *gin.Context) {
 
 	response, err := h.service.ListTransactions(c.Request.Context(), userID.(int), req)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *Handler) GetTransaction(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction id"})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, _ := c.Get("userID")
+
+	transaction, err := h.service.GetTransaction(c.Request.Context(), userID.(int), id)
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"main/dto"
+	"main/password"
 	"main/usecase"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,6 +29,15 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 
 	user, err := h.service.Register(c.Request.Context(), req)
+	if respondPasswordPolicy(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrVerificationEmailFailed) {
+		// The account exists; the user can ask for the email again
+		c.Error(err)
+		c.JSON(http.StatusCreated, user)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -39,29 +53,260 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Login(c.Request.Context(), req)
+	req.IPAddress = c.ClientIP()
+
+	tokens, err := h.service.Login(c.Request.Context(), req)
+	if respondLocked(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+
+	tokens, err := h.service.LoginTwoFactor(c.Request.Context(), req)
+	if respondLocked(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidChallenge) || errors.Is(err, usecase.ErrInvalidTwoFactorCode) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
 }
 
-func (h *UserHandler) ForgotPassword(c *gin.Context) {
-	var req dto.ForgotPasswordRequest
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	setup, err := h.service.SetupTwoFactor(c.Request.Context(), userID)
+	if errors.Is(err, usecase.ErrTwoFactorAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+func (h *UserHandler) VerifyTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	codes, err := h.service.VerifyTwoFactor(c.Request.Context(), userID, req)
+	if respondLocked(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, usecase.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrTwoFactorNotSetUp), errors.Is(err, usecase.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	err := h.service.DisableTwoFactor(c.Request.Context(), userID, req)
+	if respondLocked(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrTwoFactorNotEnabled) || errors.Is(err, usecase.ErrInvalidTwoFactorCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), req)
+	if errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req dto.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Token details set by auth middleware
+	userID := c.GetInt("userID")
+	jti := c.GetString("tokenID")
+	expiresAt := c.GetTime("tokenExpiresAt")
+
+	err := h.service.Logout(c.Request.Context(), userID, jti, expiresAt, req)
+	if errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IPAddress = c.ClientIP()
+
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
+
+	tokens, err := h.service.ChangePassword(c.Request.Context(), userID, req)
+	if respondLocked(c, err) || respondPasswordPolicy(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, usecase.ErrIncorrectPassword), errors.Is(err, usecase.ErrPasswordUnchanged):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	err := h.service.VerifyEmail(c.Request.Context(), token)
+	if errors.Is(err, usecase.ErrInvalidVerificationToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := c.GetInt("userID")
 
-	resetCode, err := h.service.ForgotPassword(c.Request.Context(), req)
+	err := h.service.ResendVerificationEmail(c.Request.Context(), userID)
+	if errors.Is(err, usecase.ErrEmailAlreadyVerified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"reset_code": resetCode})
+	// Respond the same way whether or not the email is registered; failures
+	// are recorded for the request log instead of being shown to the caller.
+	if err := h.service.ForgotPassword(c.Request.Context(), req); err != nil {
+		c.Error(err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a password reset link has been sent"})
 }
 
 func (h *UserHandler) ResetPassword(c *gin.Context) {
@@ -71,11 +316,52 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	req.IPAddress = c.ClientIP()
+
 	err := h.service.ResetPassword(c.Request.Context(), req)
-	if err != nil {
+	if respondLocked(c, err) || respondPasswordPolicy(c, err) {
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidResetCode) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "password reset successful"})
 }
+
+// respondLocked answers 429 with the unlock time if err is a lockout.
+func respondLocked(c *gin.Context, err error) bool {
+	var locked *usecase.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(time.Until(locked.UnlockAt).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":     locked.Error(),
+		"unlock_at": locked.UnlockAt,
+	})
+	return true
+}
+
+// respondPasswordPolicy answers 400 listing the broken rules if err is a
+// password policy violation.
+func respondPasswordPolicy(c *gin.Context, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "password does not meet the policy",
+		"violations": policyErr.Violations,
+	})
+	return true
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"main/dto"
+	"main/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ipLockoutService fails every login and locks a client IP out after
+// freeAttempts failures, like the per-IP lockout scope.
+type ipLockoutService struct {
+	usecase.Service
+	freeAttempts int
+	failures     map[string]int
+}
+
+func (s *ipLockoutService) Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error) {
+	if s.failures[req.IPAddress] >= s.freeAttempts {
+		return nil, &usecase.LockedError{UnlockAt: time.Now().Add(time.Minute)}
+	}
+	s.failures[req.IPAddress]++
+	return nil, usecase.ErrInvalidCredentials
+}
+
+func TestLoginLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &ipLockoutService{freeAttempts: 3, failures: make(map[string]int)}
+
+	// Trusting no proxies is the default configuration
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	router.POST("/login", NewUserHandler(service).Login)
+
+	want := []int{
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		http.StatusTooManyRequests,
+		http.StatusTooManyRequests,
+	}
+	for i, status := range want {
+		// A different account and forwarded address for every guess
+		body := fmt.Sprintf(`{"email":"user%d@example.com","password":"guess"}`, i)
+		request := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i+1))
+		request.RemoteAddr = "192.0.2.1:1234"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+
+		if response.Code != status {
+			t.Errorf("attempt %d: status = %d, want %d", i+1, response.Code, status)
+		}
+	}
+	if got := service.failures["192.0.2.1"]; got != 3 {
+		t.Errorf("failures counted for the peer address = %d, want 3", got)
+	}
+}
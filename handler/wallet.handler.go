@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"main/dto"
+	"main/funding"
+	"main/repository"
+	"main/usecase"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletHandler struct {
+	service usecase.WalletService
+}
+
+func NewWalletHandler(service usecase.WalletService) *WalletHandler {
+	return &WalletHandler{service: service}
+}
+
+func (h *WalletHandler) GetWalletDetails(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, _ := c.Get("userID")
+
+	wallet, err := h.service.GetWalletDetails(c.Request.Context(), userID.(int))
+	if errors.Is(err, repository.ErrWalletNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) Transfer(c *gin.Context) {
+	var req dto.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, _ := c.Get("userID")
+
+	transaction, err := h.service.Transfer(c.Request.Context(), userID.(int), req)
+	switch {
+	case errors.Is(err, usecase.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrInvalidAmount),
+		errors.Is(err, usecase.ErrSelfTransfer),
+		errors.Is(err, usecase.ErrInsufficientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
+
+func (h *WalletHandler) TopUp(c *gin.Context) {
+	var req dto.TopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, _ := c.Get("userID")
+
+	transaction, err := h.service.TopUp(c.Request.Context(), userID.(int), req)
+	switch {
+	case errors.Is(err, repository.ErrSourceOfFundNotFound),
+		errors.Is(err, usecase.ErrInvalidAmount),
+		errors.Is(err, usecase.ErrAmountOutOfRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, funding.ErrSourceUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to top up"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
+
+func (h *WalletHandler) ListSourcesOfFund(c *gin.Context) {
+	sources, err := h.service.ListSourcesOfFund(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sources of fund"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sources_of_fund": sources})
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"main/infra"
+	"main/mailer"
+	"main/migration"
+	"main/worker"
+)
+
+// Database pings the database and reports the connection pool statistics.
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) (interface{}, error) {
+		err := db.PingContext(ctx)
+		return infra.Stats(db), err
+	}
+}
+
+type migrationDetail struct {
+	Version int `json:"version"`
+	Latest  int `json:"latest"`
+}
+
+// Migrations fails unless the schema is at the version this binary expects.
+func Migrations(migrator *migration.Migrator) Check {
+	return func(ctx context.Context) (interface{}, error) {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		detail := migrationDetail{Version: version, Latest: migrator.Latest()}
+		if detail.Version != detail.Latest {
+			return detail, fmt.Errorf("schema is at version %d, expected %d", detail.Version, detail.Latest)
+		}
+		return detail, nil
+	}
+}
+
+// Mailer checks the mailer can deliver, for mailers that support it.
+func Mailer(m mailer.Mailer) Check {
+	return func(ctx context.Context) (interface{}, error) {
+		pinger, ok := m.(mailer.Pinger)
+		if !ok {
+			return nil, nil
+		}
+		return nil, pinger.Ping(ctx)
+	}
+}
+
+// Workers fails if any background worker has stopped.
+func Workers(group *worker.Group) Check {
+	return func(ctx context.Context) (interface{}, error) {
+		statuses := group.Statuses()
+		for _, s := range statuses {
+			if !s.Running {
+				return statuses, fmt.Errorf("worker %s is not running", s.Name)
+			}
+		}
+		return statuses, nil
+	}
+}
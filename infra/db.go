@@ -1,32 +1,126 @@
 package infra
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	_ "github.com/jackc/pgx/v4/stdlib"
-	"github.com/joho/godotenv"
-	"log"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/sirupsen/logrus"
 )
 
-func ConnectDB() (*sql.DB, error) {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %s", err)
+// DBConfig describes the PostgreSQL connection pool. DSN, when set, takes
+// precedence over the discrete connection fields.
+type DBConfig struct {
+	DSN      string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout aborts queries running longer than this; zero leaves
+	// the server default.
+	StatementTimeout time.Duration
+
+	// ConnectAttempts is how many times the initial ping is tried, waiting
+	// ConnectBackoff after the first failure and doubling it each time.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+}
+
+// ConnString returns the connection string for the configuration.
+func (c DBConfig) ConnString() string {
+	if c.DSN != "" {
+		return c.DSN
 	}
 
-	dbUrl := os.Getenv("DATABASE_URL")
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/" + c.Name,
+	}
+	if c.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {c.SSLMode}}.Encode()
+	}
+	return u.String()
+}
 
-	db, err := sql.Open("pgx", dbUrl)
+// OpenDB opens the connection pool and waits for the database to answer,
+// retrying with exponential backoff so the service can start alongside it.
+func OpenDB(ctx context.Context, config DBConfig, logger logrus.FieldLogger) (*sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(config.ConnString())
 	if err != nil {
-		return nil, fmt.Errorf("unable to connect to database: %v\n", err)
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+	if config.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
 	}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("unable to ping database: %v", err)
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	attempts := config.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := config.ConnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logger.WithError(err).Warnf("Database not ready (attempt %d/%d), retrying in %s", attempt, attempts, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		}
+		backoff *= 2
 	}
 
-	fmt.Println("Successfully connected to database")
-	return db, nil
+	db.Close()
+	return nil, fmt.Errorf("unable to ping database after %d attempts: %w", attempts, err)
+}
+
+// PoolStats is a snapshot of the connection pool, for health checks.
+type PoolStats struct {
+	MaxOpenConnections int           `json:"max_open_connections"`
+	OpenConnections    int           `json:"open_connections"`
+	InUse              int           `json:"in_use"`
+	Idle               int           `json:"idle"`
+	WaitCount          int64         `json:"wait_count"`
+	WaitDuration       time.Duration `json:"wait_duration"`
+}
+
+func Stats(db *sql.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+	}
 }
@@ -0,0 +1,72 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ServeConfig struct {
+	// ShutdownTimeout is how long in-flight requests get to finish.
+	ShutdownTimeout time.Duration
+	// ShutdownDelay keeps serving for a while after the shutdown signal, so
+	// load balancers can notice the failing readiness probe first.
+	ShutdownDelay time.Duration
+	// BeforeShutdown, if set, is called as soon as the shutdown signal
+	// arrives.
+	BeforeShutdown func()
+}
+
+// Serve runs srv until ctx is cancelled, then shuts it down gracefully: the
+// listener closes and in-flight requests get up to config.ShutdownTimeout to
+// finish. It returns early if the server fails to start.
+func Serve(ctx context.Context, srv *http.Server, config ServeConfig, logger logrus.FieldLogger) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ServeListener(ctx, srv, listener, config, logger)
+}
+
+// ServeListener is like Serve but accepts connections on listener.
+func ServeListener(ctx context.Context, srv *http.Server, listener net.Listener, config ServeConfig, logger logrus.FieldLogger) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Infof("Server starting on %s", listener.Addr())
+		serverErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	if config.BeforeShutdown != nil {
+		config.BeforeShutdown()
+	}
+	if config.ShutdownDelay > 0 {
+		logger.Infof("Shutdown requested, still serving for %s", config.ShutdownDelay)
+		time.Sleep(config.ShutdownDelay)
+	}
+
+	logger.Infof("Shutting down, waiting up to %s for in-flight requests", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package keyring holds the keys used to sign and verify access tokens. One
+// key signs new tokens; any number of older keys stay available for
+// verification so keys can be rotated without logging everybody out.
+package keyring
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+)
+
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signingKey is nil for verification-only keys.
+	signingKey   interface{}
+	verifyingKey interface{}
+}
+
+// NewKey wraps an RSA or ECDSA private or public key. Its ID is the key's
+// RFC 7638 thumbprint.
+func NewKey(key interface{}) (*Key, error) {
+	var public crypto.PublicKey
+	var signingKey interface{}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		public, signingKey = &k.PublicKey, k
+	case *ecdsa.PrivateKey:
+		public, signingKey = &k.PublicKey, k
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	method, err := methodFor(public)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := publicJWK(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:           jwk.thumbprint(),
+		Method:       method,
+		signingKey:   signingKey,
+		verifyingKey: public,
+	}, nil
+}
+
+// NewHMACKey wraps a shared secret. HMAC keys are never published in the
+// JWKS.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:           id,
+		Method:       jwt.SigningMethodHS256,
+		signingKey:   secret,
+		verifyingKey: secret,
+	}
+}
+
+func methodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		}
+		return nil, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+	}
+	return nil, fmt.Errorf("unsupported key type %T", public)
+}
+
+func (k *Key) CanSign() bool {
+	return k.signingKey != nil
+}
+
+// Keyring is read-only once built, so it is safe for concurrent use. Keys
+// are rotated by restarting with a new signing key and the old one listed
+// as a verification key.
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// New returns a keyring that signs with signing and also accepts tokens
+// signed by any of the verification keys.
+func New(signing *Key, verification ...*Key) (*Keyring, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, errors.New("signing key must include a private key")
+	}
+
+	k := &Keyring{signing: signing, keys: map[string]*Key{signing.ID: signing}}
+	for _, key := range verification {
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// Sign signs the claims with the current signing key and records its ID in
+// the token's "kid" header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.signingKey)
+}
+
+// Keyfunc resolves the verification key for jwt.Parse. Tokens without a kid
+// are checked against the signing key.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key := k.signing
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = k.keys[kid]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	// Reject tokens whose algorithm differs from the key's, so e.g. an RSA
+	// public key can never be used as an HMAC secret.
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedMethod
+	}
+
+	return key.verifyingKey, nil
+}
@@ -0,0 +1,74 @@
+package keyring
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadPEMFiles builds a keyring signing with the private key in signingPath
+// and accepting tokens signed by the keys in verificationPaths, which may
+// hold public or private keys.
+func LoadPEMFiles(signingPath string, verificationPaths ...string) (*Keyring, error) {
+	signing, err := LoadPEMFile(signingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := make([]*Key, 0, len(verificationPaths))
+	for _, path := range verificationPaths {
+		key, err := LoadPEMFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return New(signing, verification...)
+}
+
+func LoadPEMFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePEM reads the first key in PEM data: PKCS#1 or PKCS#8 RSA, SEC 1 or
+// PKCS#8 EC, or a PKIX public key.
+func ParsePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(key)
+}
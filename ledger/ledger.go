@@ -0,0 +1,72 @@
+// Package ledger builds and checks double-entry journal entries. Postings
+// use the debit-positive convention: every entry's postings sum to zero, and
+// so does the whole ledger.
+package ledger
+
+import (
+	"errors"
+	"main/entity"
+	"main/money"
+)
+
+var ErrUnbalanced = errors.New("journal entry is not balanced")
+
+// Validate checks that the entry has at least two non-zero postings in a
+// single currency that sum to zero.
+func Validate(entry *entity.JournalEntry) error {
+	if len(entry.Postings) < 2 {
+		return errors.New("journal entry needs at least two postings")
+	}
+
+	currency := entry.Postings[0].Amount.Currency()
+	total := money.Zero(currency)
+	for _, p := range entry.Postings {
+		if p.Amount.IsZero() {
+			return errors.New("journal entry has a zero posting")
+		}
+		if p.Amount.Currency() != currency {
+			return errors.New("journal entry mixes currencies")
+		}
+		total = total.Add(p.Amount)
+	}
+
+	if !total.IsZero() {
+		return ErrUnbalanced
+	}
+	return nil
+}
+
+// NormalBalance converts a raw posting sum (debits minus credits) into the
+// balance as the account holder sees it: credit-normal accounts such as
+// wallets grow with credits.
+func NormalBalance(accountType string, sum money.Amount) money.Amount {
+	switch accountType {
+	case entity.LedgerAccountTypeLiability, entity.LedgerAccountTypeRevenue:
+		return sum.Neg()
+	default:
+		return sum
+	}
+}
+
+// Transfer moves amount from one wallet account to another.
+func Transfer(transactionID int, from, to *entity.LedgerAccount, amount money.Amount, description string) *entity.JournalEntry {
+	return entry(transactionID, description, from, to, amount)
+}
+
+// TopUp records money arriving from an external source of fund: the system
+// float grows and so does the wallet's claim on it.
+func TopUp(transactionID int, float, wallet *entity.LedgerAccount, amount money.Amount, description string) *entity.JournalEntry {
+	return entry(transactionID, description, float, wallet, amount)
+}
+
+// entry debits the debit account and credits the credit account.
+func entry(transactionID int, description string, debit, credit *entity.LedgerAccount, amount money.Amount) *entity.JournalEntry {
+	return &entity.JournalEntry{
+		TransactionID: &transactionID,
+		Description:   description,
+		Postings: []entity.Posting{
+			{AccountID: debit.ID, Amount: amount},
+			{AccountID: credit.ID, Amount: amount.Neg()},
+		},
+	}
+}
@@ -0,0 +1,37 @@
+// Package logging carries a request-scoped logger through contexts and
+// keeps secrets out of log output.
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey struct{}
+
+var base = logrus.NewEntry(logrus.StandardLogger())
+
+// SetDefault sets the logger returned for contexts that carry none, such as
+// those of background jobs started before any request.
+func SetDefault(logger *logrus.Logger) {
+	base = logrus.NewEntry(logger)
+}
+
+// NewContext returns a copy of ctx carrying entry.
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the logger carried by ctx, or the default one.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return base
+}
+
+// WithFields returns a copy of ctx whose logger also carries fields.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithFields(fields))
+}
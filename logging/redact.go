@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched against lower-cased field names.
+var sensitiveKeys = []string{"password", "passwd", "token", "secret", "authorization", "cookie", "recovery"}
+
+var sensitivePatterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	// Query parameters and key=value pairs, e.g. the code in a reset link
+	{regexp.MustCompile(`(?i)\b((?:[a-z_]*password|[a-z_]*token|[a-z_]*secret|[a-z_]*code)=)[^&\s"']+`), "${1}" + redacted},
+	// Bearer credentials
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[^\s"']+`), "${1}" + redacted},
+	// JWTs wherever they appear
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redacted},
+}
+
+// RedactingFormatter blanks out sensitive fields and scrubs credentials from
+// the message and string values before handing the entry to Formatter.
+type RedactingFormatter struct {
+	logrus.Formatter
+}
+
+func (f RedactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	clean := *entry
+	clean.Message = Redact(entry.Message)
+	clean.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		clean.Data[key] = redactField(key, value)
+	}
+	return f.Formatter.Format(&clean)
+}
+
+// Redact scrubs credentials from free text.
+func Redact(s string) string {
+	for _, p := range sensitivePatterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+func redactField(key string, value interface{}) interface{} {
+	switch value.(type) {
+	case bool, int, int64, float64:
+		// Counts and flags, such as a number of purged tokens
+		return value
+	}
+
+	if isSensitiveKey(key) {
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case error:
+		return Redact(v.Error())
+	default:
+		return value
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if key == "code" || strings.HasSuffix(key, "_code") && key != "status_code" {
+		return true
+	}
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
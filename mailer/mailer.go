@@ -0,0 +1,20 @@
+package mailer
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Pinger is implemented by mailers that can check they are able to deliver
+// without sending anything.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
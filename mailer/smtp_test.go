@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSMTPSendGivesUpOnHungServer(t *testing.T) {
+	// The server accepts connections but never sends its greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m := NewSMTPMailer(host, port, "", "", "from@example.com", 100*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Send(context.Background(), Message{To: "to@example.com", Subject: "Hi", Body: "Hello"})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Send succeeded against a server that never answered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not give up on a hung server")
+	}
+}
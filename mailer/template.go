@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.txt
+var templateFiles embed.FS
+
+var templates = template.Must(template.ParseFS(templateFiles, "templates/*.txt"))
+
+// Template names.
+const (
+	TemplatePasswordReset = "password_reset.txt"
+	TemplateEmailChanged  = "email_changed.txt"
+	TemplateVerification  = "email_verification.txt"
+)
+
+// NewMessage renders the named template for the recipient. Templates start
+// with a "Subject:" line followed by a blank line and the body.
+func NewMessage(to, name string, data interface{}) (Message, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return Message{}, err
+	}
+
+	header, body, ok := strings.Cut(buf.String(), "\n\n")
+	subject, found := strings.CutPrefix(header, "Subject: ")
+	if !ok || !found {
+		return Message{}, fmt.Errorf("template %s has no subject line", name)
+	}
+
+	return Message{To: to, Subject: subject, Body: body}, nil
+}
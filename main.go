@@ -2,136 +2,250 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"main/config"
+	"main/funding"
 	auth "main/handler"
+	"main/health"
+	"main/infra"
+	"main/keyring"
+	"main/logging"
+	"main/mailer"
 	"main/middleware"
+	"main/migration"
+	"main/ratelimit"
 	"main/repository"
+	"main/tracing"
 	"main/usecase"
+	"main/worker"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
-type Config struct {
-	DBHost      string
-	DBPort      string
-	DBUser      string
-	DBPassword  string
-	DBName      string
-	ServerPort  string
-	JWTSecret   string
-	JWTIssuer   string
-	JWTDuration time.Duration
-}
-
-func loadConfig() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
+// printConfig writes the effective configuration, secrets redacted, for the
+// "config print" subcommand.
+func printConfig(cfg *config.Config) error {
+	output, err := cfg.YAML()
+	if err != nil {
+		return err
 	}
+	_, err = os.Stdout.Write(output)
+	return err
+}
 
-	config := &Config{
-		DBHost:      getEnv("DB_HOST", "localhost"),
-		DBPort:      getEnv("DB_PORT", "5432"),
-		DBUser:      getEnv("DB_USER", "postgres"),
-		DBPassword:  getEnv("DB_PASSWORD", "Kamji^50"),
-		DBName:      getEnv("DB_NAME", "e-wallet_db"),
-		ServerPort:  getEnv("SERVER_PORT", "8080"),
-		JWTSecret:   getEnv("JWT_SECRET", "=-0=-0"),
-		JWTIssuer:   getEnv("JWT_ISSUER", "ewallet-api"),
-		JWTDuration: 24 * time.Hour,
+func lockoutPolicy(c config.LockoutConfig) usecase.LockoutPolicy {
+	return usecase.LockoutPolicy{
+		FreeAttempts: c.FreeAttempts,
+		BaseDelay:    c.BaseDelay,
+		MaxDelay:     c.MaxDelay,
+		ResetAfter:   c.ResetAfter,
 	}
-
-	return config, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// throttleTTL keeps failed-attempt counters for as long as either lockout
+// policy may still count them.
+func throttleTTL(cfg *config.Config) time.Duration {
+	ttl := cfg.Auth.AccountLockout.ResetAfter
+	if cfg.Auth.IPLockout.ResetAfter > ttl {
+		ttl = cfg.Auth.IPLockout.ResetAfter
 	}
-	return defaultValue
+	return ttl
 }
 
 func setupLogger() *logrus.Logger {
 	logger := logrus.New()
-	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetFormatter(logging.RedactingFormatter{Formatter: &logrus.JSONFormatter{}})
 	logger.SetOutput(os.Stdout)
+	logging.SetDefault(logger)
 	return logger
 }
 
-func setupDatabase(config *Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.DBHost, config.DBPort, config.DBUser, config.DBPassword, config.DBName,
-	)
+// setupKeyring signs tokens with the PEM key in auth.signing_key_file when
+// set, falling back to HS256 with auth.jwt_secret otherwise.
+func setupKeyring(cfg *config.Config) (*keyring.Keyring, error) {
+	if cfg.Auth.SigningKeyFile == "" {
+		return keyring.New(keyring.NewHMACKey("default", []byte(cfg.Auth.JWTSecret)))
+	}
+	return keyring.LoadPEMFiles(cfg.Auth.SigningKeyFile, cfg.Auth.VerificationKeyFiles...)
+}
+
+func setupMailer(cfg *config.Config) (mailer.Mailer, error) {
+	mail := cfg.Mail
+	switch mail.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mail.SMTP.Host, mail.SMTP.Port, mail.SMTP.Username, mail.SMTP.Password, mail.From, mail.SMTP.Timeout), nil
+	case "file":
+		return mailer.NewFileMailer(mail.Dir, mail.From)
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", mail.Driver)
+	}
+}
+
+func runMigrations(ctx context.Context, db *sql.DB, logger *logrus.Logger, args []string) error {
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Infof("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Infof("Reverted migration %04d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied at " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down or status)", command)
+	}
+}
 
-	db, err := sql.Open("postgres", dsn)
+func verifyLedger(ctx context.Context, ledgerService usecase.LedgerService) error {
+	report, err := ledgerService.VerifyBooks(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
 	}
+	fmt.Println(string(output))
 
-	return db, nil
+	if !report.Balanced {
+		return errors.New("books are not balanced")
+	}
+	return nil
 }
 
-func setupRouter(logger *logrus.Logger, authHandler *auth.UserHandler, authMiddleware gin.HandlerFunc) *gin.Engine {
+func setupRouter(logger *logrus.Logger, healthHandler *auth.HealthHandler, authHandler *auth.UserHandler, walletHandler *auth.WalletHandler, txHandler *auth.Handler, keyHandler *auth.KeyHandler, profileHandler *auth.ProfileHandler, authMiddleware, idempotencyMiddleware gin.HandlerFunc, rateLimit func(group string) gin.HandlerFunc) *gin.Engine {
 	router := gin.New()
 
 	// Middleware
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID(logger))
+	router.Use(middleware.Tracing())
 	router.Use(corsMiddleware())
-	router.Use(requestLogger(logger))
+	router.Use(requestLogger())
+	router.Use(middleware.Metrics())
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	// Health checks; /health is kept for existing monitors and reports
+	// readiness
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+	router.GET("/health", healthHandler.Readyz)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Token verification keys
+	router.GET("/.well-known/jwks.json", keyHandler.JWKS)
 
 	// Public routes
-	router.POST("/register", authHandler.Register)
-	router.POST("/login", authHandler.Login)
-	router.POST("/forgot-password", authHandler.ForgotPassword)
-	router.POST("/reset-password", authHandler.ResetPassword)
+	router.POST("/register", rateLimit("register"), authHandler.Register)
+	router.POST("/login", rateLimit("login"), authHandler.Login)
+	router.POST("/login/2fa", rateLimit("login"), authHandler.LoginTwoFactor)
+	router.POST("/forgot-password", rateLimit("password"), authHandler.ForgotPassword)
+	router.POST("/reset-password", rateLimit("password"), authHandler.ResetPassword)
+	router.GET("/verify-email", rateLimit("password"), authHandler.VerifyEmail)
+
+	// Token routes
+	tokens := router.Group("/auth")
+	tokens.Use(rateLimit("auth"))
+	{
+		tokens.POST("/refresh", authHandler.Refresh)
+		tokens.POST("/logout", authMiddleware, authHandler.Logout)
+	}
 
 	// Protected routes
 	api := router.Group("/api")
 	api.Use(authMiddleware)
-	//{
-	//	// User routes
-	//	api.GET("/profile", getUserProfile) //
-	//	api.PUT("/profile", updateProfile)  //
-	//
-	//	// Wallet routes
-	//	wallet := api.Group("/wallet")
-	//	{
-	//		wallet.GET("", getWalletDetails)        //
-	//		wallet.POST("/topup", topUpWallet)      //
-	//		wallet.POST("/transfer", transferMoney) //
-	//	}
-	//
-	//	// Transaction routes
+	api.Use(rateLimit("api"))
+
+	// User routes
+	api.GET("/profile", profileHandler.GetProfile)
+	api.PUT("/profile", profileHandler.UpdateProfile)
+	api.PUT("/password", authHandler.ChangePassword)
+	api.POST("/verify-email/resend", rateLimit("email"), authHandler.ResendVerificationEmail)
+
+	// Wallet routes. Only money-moving requests are idempotent: stored
+	// responses must never hold secrets such as recovery codes.
+	wallet := api.Group("/wallet")
+	{
+		wallet.GET("", walletHandler.GetWalletDetails)
+		wallet.POST("/topup", idempotencyMiddleware, walletHandler.TopUp)
+		wallet.POST("/transfer", idempotencyMiddleware, walletHandler.Transfer)
+	}
+	api.GET("/sources-of-fund", walletHandler.ListSourcesOfFund)
+
+	// Two-factor authentication routes
+	twoFactor := api.Group("/2fa")
+	{
+		twoFactor.POST("/setup", authHandler.SetupTwoFactor)
+		twoFactor.POST("/verify", authHandler.VerifyTwoFactor)
+		twoFactor.POST("/disable", authHandler.DisableTwoFactor)
+	}
+
+	// Transaction routes
 	transactions := api.Group("/transactions")
 	{
-		transactions.GET("")
-		//transactions.GET("/:id", getTransaction)
+		transactions.GET("", txHandler.ListTransactions)
+		transactions.GET("/:id", txHandler.GetTransaction)
 	}
-	//
-	//	// Game routes
+
+	// Game routes
 	//	game := api.Group("/game")
 	//	{
 	//		game.GET("/attempts", getGameAttempts) // TODO: Implement this
 	//		game.POST("/play", playGame)           // TODO: Implement this
 	//	}
-	//}
 
 	return router
 }
@@ -140,7 +254,8 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Authorization, Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Authorization, Content-Type, Idempotency-Key, X-Request-ID, Traceparent")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -151,7 +266,9 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-func requestLogger(logger *logrus.Logger) gin.HandlerFunc {
+// requestLogger logs each request through the logger RequestID put in its
+// context, which by now also carries the user ID of authenticated requests.
+func requestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
@@ -161,20 +278,40 @@ func requestLogger(logger *logrus.Logger) gin.HandlerFunc {
 
 		// Log request
 		duration := time.Since(start)
-		logger.WithFields(logrus.Fields{
+		entry := logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
 			"method":     c.Request.Method,
 			"path":       c.Request.URL.Path,
 			"status":     c.Writer.Status(),
 			"duration":   duration.String(),
 			"client_ip":  c.ClientIP(),
 			"user_agent": c.Request.UserAgent(),
-		}).Info("Request processed")
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("errors", c.Errors.String())
+		}
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			entry.Error("Request failed")
+			return
+		}
+		entry.Info("Request processed")
 	}
 }
 
 func main() {
 	// Load configuration
-	config, err := loadConfig()
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		if err := printConfig(cfg); err != nil {
+			log.Fatalf("Failed to print config: %v", err)
+		}
+		return
+	}
+
+	rateLimits, err := cfg.RateLimitPolicies()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
@@ -182,43 +319,212 @@ func main() {
 	// Setup logger
 	logger := setupLogger()
 
-	// Setup database
-	db, err := setupDatabase(config)
+	// Setup tracing
+	shutdownTracing, err := tracing.Setup(tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		logger.Fatalf("Failed to setup tracing: %v", err)
+	}
+
+	// Setup database; it is closed explicitly once the server has drained
+	db, err := infra.OpenDB(context.Background(), cfg.DB(), logger)
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer db.Close()
+
+	// Run migrations: either as the "migrate" subcommand or on startup
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrations(context.Background(), db, logger, os.Args[2:]); err != nil {
+			logger.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if cfg.Database.AutoMigrate {
+		if err := runMigrations(context.Background(), db, logger, nil); err != nil {
+			logger.Fatalf("Migration failed: %v", err)
+		}
+	}
+
+	// Setup token signing keys
+	keys, err := setupKeyring(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Setup mail delivery
+	mail, err := setupMailer(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to setup mailer: %v", err)
+	}
 
 	// Initialize repositories
 	authRepo := repository.NewUserRepository(db)
-	//transactionRepo := repository.NewTransactionRepository(db)
+	walletRepo := repository.NewWalletRepository(db)
+	transactionRepo := repository.NewTransactionRepository(db)
+	sourceOfFundRepo := repository.NewSourceOfFundRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	throttleRepo := repository.NewThrottleRepository(db)
+	transactor := repository.NewTransactor(db)
 
 	// Initialize services
 	authService := usecase.NewService(
 		authRepo,
-		config.JWTSecret,
-		config.JWTIssuer,
-		config.JWTDuration,
+		tokenRepo,
+		twoFactorRepo,
+		throttleRepo,
+		transactor,
+		keys,
+		mail,
+		usecase.ServiceConfig{
+			JWTIssuer:            cfg.Auth.Issuer,
+			JWTDuration:          cfg.Auth.AccessTokenTTL,
+			RefreshDuration:      cfg.Auth.RefreshTokenTTL,
+			ChallengeDuration:    cfg.Auth.ChallengeTTL,
+			VerificationDuration: cfg.Auth.VerificationTTL,
+			ResetCodeDuration:    cfg.Auth.ResetCodeTTL,
+			AppName:              cfg.App.Name,
+			AppBaseURL:           cfg.App.BaseURL,
+			AccountLockout:       lockoutPolicy(cfg.Auth.AccountLockout),
+			IPLockout:            lockoutPolicy(cfg.Auth.IPLockout),
+			PasswordPolicy:       cfg.PasswordPolicy(),
+		},
+	)
+
+	profileService := usecase.NewProfileService(authRepo, walletRepo, mail, authService)
+
+	walletService := usecase.NewWalletService(
+		walletRepo,
+		authRepo,
+		transactionRepo,
+		sourceOfFundRepo,
+		ledgerRepo,
+		transactor,
+		funding.NewMockRegistry(),
+		usecase.WalletServiceConfig{
+			RequireVerifiedEmail: cfg.Wallet.RequireVerifiedEmail,
+		},
+	)
+
+	transactionService := usecase.NewTransactionService(
+		transactionRepo,
+		usecase.TransactionServiceConfig{
+			DefaultPageSize: cfg.Pagination.DefaultPageSize,
+			MaxPageSize:     cfg.Pagination.MaxPageSize,
+		},
 	)
 
-	//transactionService := usecase.NewTransactionService(
-	//	transactionRepo,
-	//)
+	ledgerService := usecase.NewLedgerService(ledgerRepo)
+
+	janitorService := usecase.NewJanitorService(
+		idempotencyRepo,
+		tokenRepo,
+		throttleRepo,
+		usecase.JanitorConfig{
+			IdempotencyKeyTTL: cfg.Janitor.IdempotencyKeyTTL,
+			ThrottleTTL:       throttleTTL(cfg),
+		},
+	)
 	// TODO: Initialize other services
 
+	// Verify the books when run as the "ledger verify" subcommand
+	if len(os.Args) > 2 && os.Args[1] == "ledger" && os.Args[2] == "verify" {
+		if err := verifyLedger(context.Background(), ledgerService); err != nil {
+			logger.Fatalf("Ledger verification failed: %v", err)
+		}
+		return
+	}
+
+	// Background workers, started with the server
+	workers := worker.NewGroup(logger)
+	workers.Add("janitor", cfg.Janitor.Interval, janitorService.PurgeExpired)
+
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "ewallet"))
+
+	// Readiness checks
+	migrator, err := migration.NewMigrator(db)
+	if err != nil {
+		logger.Fatalf("Failed to load migrations: %v", err)
+	}
+	healthChecks := health.NewRegistry(cfg.Health.CheckTimeout)
+	healthChecks.Register("database", health.Database(db))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	healthChecks.Register("mailer", health.Mailer(mail))
+	healthChecks.Register("workers", health.Workers(workers))
+
 	// Initialize handlers
+	healthHandler := auth.NewHealthHandler(healthChecks)
 	authHandler := auth.NewUserHandler(authService)
-	//txHandler := auth.NewTransactionHandler(transactionService)
+	walletHandler := auth.NewWalletHandler(walletService)
+	txHandler := auth.NewTransactionHandler(transactionService)
+	keyHandler := auth.NewKeyHandler(keys)
+	profileHandler := auth.NewProfileHandler(profileService)
 
 	// TODO: Initialize other handlers
 
+	// Rate limits are kept in memory, so they apply per instance
+	rateLimitStore := ratelimit.NewMemoryStore()
+	rateLimit := func(group string) gin.HandlerFunc {
+		return middleware.RateLimit(rateLimitStore, group, rateLimits[group])
+	}
+
 	// Setup router
-	router := setupRouter(logger, authHandler, middleware.AuthMiddleware(authService))
+	router := setupRouter(logger, healthHandler, authHandler, walletHandler, txHandler, keyHandler, profileHandler,
+		middleware.AuthMiddleware(authService),
+		middleware.Idempotency(idempotencyRepo),
+		rateLimit,
+	)
+	// Only trusted proxies may set the client IP that rate limits and
+	// lockouts are keyed by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background workers
+	workers.Start(ctx)
 
 	// Start server
-	serverAddr := fmt.Sprintf(":%s", config.ServerPort)
-	logger.Infof("Server starting on %s", serverAddr)
-	if err := router.Run(serverAddr); err != nil {
-		logger.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Server.Port),
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	serveErr := infra.Serve(ctx, srv, infra.ServeConfig{
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		ShutdownDelay:   cfg.Server.ShutdownDelay,
+		BeforeShutdown:  healthChecks.SetShuttingDown,
+	}, logger)
+	if serveErr != nil {
+		logger.Errorf("Server stopped: %v", serveErr)
+	}
+
+	// Stop workers, then close the database they and the handlers share
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := workers.Stop(stopCtx); err != nil {
+		logger.Errorf("Failed to stop workers: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		logger.Errorf("Failed to close database: %v", err)
+	}
+	if err := shutdownTracing(stopCtx); err != nil {
+		logger.Errorf("Failed to flush traces: %v", err)
+	}
+
+	if serveErr != nil {
+		os.Exit(1)
 	}
+	logger.Info("Shutdown complete")
 }
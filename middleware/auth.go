@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"github.com/golang-jwt/jwt"
+	"main/logging"
 	"main/usecase"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 func AuthMiddleware(authService usecase.Service) gin.HandlerFunc {
@@ -35,13 +38,58 @@ func AuthMiddleware(authService usecase.Service) gin.HandlerFunc {
 			return
 		}
 
+		if claims["typ"] != usecase.TokenTypeAccess {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token type"})
+			return
+		}
+
 		userID, ok := claims["sub"].(float64)
 		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user id in token"})
 			return
 		}
 
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token id in token"})
+			return
+		}
+
+		revoked, err := authService.IsTokenRevoked(c.Request.Context(), jti)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to validate token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+
+		// Tokens issued before the user's last password change are void
+		version, ok := claims["ver"].(float64)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token version in token"})
+			return
+		}
+
+		current, err := authService.IsTokenVersionCurrent(c.Request.Context(), int(userID), int(version))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to validate token"})
+			return
+		}
+		if !current {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+
+		exp, _ := claims["exp"].(float64)
+
 		c.Set("userID", int(userID))
+		c.Set("tokenID", jti)
+		c.Set("tokenExpiresAt", time.Unix(int64(exp), 0))
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), logrus.Fields{"user_id": int(userID)}))
 		c.Next()
 	}
 }
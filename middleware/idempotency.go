@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"main/entity"
+	"main/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key runs normally and its response is
+// stored; later requests by the same user with the same key and body get
+// that response replayed, and ones with a different body are rejected.
+// It must run after AuthMiddleware, and only on routes whose responses are
+// safe to store.
+func Idempotency(repo repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := c.GetInt("userID")
+		record := &entity.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: fingerprint(c.Request, body),
+		}
+
+		ctx := c.Request.Context()
+		created, err := repo.CreateIdempotencyKey(ctx, record)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to process idempotency key"})
+			return
+		}
+
+		if !created {
+			existing, err := repo.GetIdempotencyKey(ctx, userID, key)
+			if err != nil {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to process idempotency key"})
+				return
+			}
+
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was already used with a different request"})
+			case existing.CompletedAt == nil:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// A panicking handler must not leave the key reserved forever; the
+		// recovery middleware still turns the panic into a 500.
+		defer func() {
+			if p := recover(); p != nil {
+				repo.DeleteIdempotencyKey(context.WithoutCancel(ctx), userID, key)
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The response has been sent; finish bookkeeping even if the client
+		// has already gone away.
+		ctx = context.WithoutCancel(ctx)
+
+		// Server errors are not stored, so the client can retry them
+		if recorder.Status() >= http.StatusInternalServerError {
+			repo.DeleteIdempotencyKey(ctx, userID, key)
+			return
+		}
+
+		record.StatusCode = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.CompleteIdempotencyKey(ctx, record); err != nil {
+			repo.DeleteIdempotencyKey(ctx, userID, key)
+		}
+	}
+}
+
+// fingerprint identifies a request by its method, route and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
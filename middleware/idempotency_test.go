@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"main/entity"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryIdempotencyRepo keeps idempotency keys in a map.
+type memoryIdempotencyRepo struct {
+	keys map[string]*entity.IdempotencyKey
+}
+
+func (r *memoryIdempotencyRepo) CreateIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) (bool, error) {
+	if _, ok := r.keys[key.Key]; ok {
+		return false, nil
+	}
+	r.keys[key.Key] = key
+	return true, nil
+}
+
+func (r *memoryIdempotencyRepo) GetIdempotencyKey(ctx context.Context, userID int, key string) (*entity.IdempotencyKey, error) {
+	return r.keys[key], nil
+}
+
+func (r *memoryIdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) error {
+	now := time.Now()
+	key.CompletedAt = &now
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) DeleteIdempotencyKey(ctx context.Context, userID int, key string) error {
+	delete(r.keys, key)
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotencyReleasesKeyWhenHandlerPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &memoryIdempotencyRepo{keys: make(map[string]*entity.IdempotencyKey)}
+
+	router := gin.New()
+	router.Use(gin.Recovery(), Idempotency(repo))
+	router.POST("/transfer", func(c *gin.Context) { panic("boom") })
+
+	request := httptest.NewRequest(http.MethodPost, "/transfer", strings.NewReader(`{}`))
+	request.Header.Set(IdempotencyKeyHeader, "key-1")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", response.Code, http.StatusInternalServerError)
+	}
+	if _, ok := repo.keys["key-1"]; ok {
+		t.Fatal("key is still reserved after the handler panicked")
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsClampsUnknownMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.Handle("PROPFIND", "/dav", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/dav", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, method := range []string{"PROPFIND", http.MethodGet} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/dav", nil))
+	}
+
+	tests := []struct {
+		method string
+		want   float64
+	}{
+		{"OTHER", 1},
+		{http.MethodGet, 1},
+		{"PROPFIND", 0},
+	}
+	for _, tt := range tests {
+		got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues(tt.method, "/dav", "204"))
+		if got != tt.want {
+			t.Errorf("requests with method %s = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"main/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit meters requests to a route group against policy. Authenticated
+// requests are counted per user, so it should run after AuthMiddleware
+// where there is one; other requests are counted per client IP. A disabled
+// policy lets everything through.
+func RateLimit(store ratelimit.Store, group string, policy ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := group + ":ip:" + c.ClientIP()
+		if userID, exists := c.Get("userID"); exists {
+			key = group + ":user:" + strconv.Itoa(userID.(int))
+		}
+
+		result, err := store.Take(c.Request.Context(), key, policy)
+		if err != nil {
+			// Fail open: an unavailable store should not take the API down
+			c.Error(err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"main/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		want           []int
+	}{
+		// The default: the spoofed header is ignored and both requests
+		// share the peer address's bucket
+		{"no trusted proxies", nil, []int{http.StatusOK, http.StatusTooManyRequests}},
+		// Behind a trusted proxy each forwarded client has its own bucket
+		{"trusted proxy", []string{"192.0.2.1"}, []int{http.StatusOK, http.StatusOK}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.trustedProxies); err != nil {
+				t.Fatal(err)
+			}
+			policy := ratelimit.Policy{Limit: 1, Period: time.Minute}
+			router.Use(RateLimit(ratelimit.NewMemoryStore(), "login", policy))
+			router.POST("/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+				request := httptest.NewRequest(http.MethodPost, "/login", nil)
+				request.RemoteAddr = "192.0.2.1:1234"
+				request.Header.Set("X-Forwarded-For", forwardedFor)
+				response := httptest.NewRecorder()
+				router.ServeHTTP(response, request)
+
+				if response.Code != tt.want[i] {
+					t.Errorf("request %d: status = %d, want %d", i+1, response.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
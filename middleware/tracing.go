@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"main/logging"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("main/middleware")
+
+// Tracing starts a server span for each request, continuing the caller's
+// trace when it sends a W3C traceparent header, and adds the trace ID to the
+// request's logger. It must run after RequestID.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("request_id", c.GetString("requestID")),
+			),
+		)
+		defer span.End()
+
+		sc := span.SpanContext()
+		ctx = logging.WithFields(ctx, logrus.Fields{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if userID, exists := c.Get("userID"); exists {
+			span.SetAttributes(attribute.Int("user_id", userID.(int)))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, "HTTP "+strconv.Itoa(status))
+		}
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE users (
+    id                         SERIAL PRIMARY KEY,
+    username                   VARCHAR(50)  NOT NULL,
+    email                      VARCHAR(255) NOT NULL UNIQUE,
+    password_hash              VARCHAR(255) NOT NULL,
+    reset_password_code        VARCHAR(255),
+    reset_password_code_expiry TIMESTAMPTZ,
+    created_at                 TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at                 TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS wallets;
+DROP FUNCTION IF EXISTS generate_wallet_number();
+DROP SEQUENCE IF EXISTS wallet_number_seq;
@@ -0,0 +1,16 @@
+CREATE SEQUENCE wallet_number_seq START 1;
+
+CREATE FUNCTION generate_wallet_number() RETURNS VARCHAR AS $$
+BEGIN
+    RETURN '777' || LPAD(nextval('wallet_number_seq')::TEXT, 10, '0');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE wallets (
+    id            SERIAL PRIMARY KEY,
+    wallet_number VARCHAR(13)    NOT NULL UNIQUE,
+    user_id       INT            NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+    balance       NUMERIC(15, 2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at    TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
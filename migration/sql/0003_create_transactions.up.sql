@@ -0,0 +1,14 @@
+CREATE TABLE transactions (
+    id                SERIAL PRIMARY KEY,
+    from_wallet_id    INT REFERENCES wallets (id),
+    to_wallet_id      INT            NOT NULL REFERENCES wallets (id),
+    amount            NUMERIC(15, 2) NOT NULL CHECK (amount > 0),
+    description       VARCHAR(255)   NOT NULL DEFAULT '',
+    source_of_fund_id INT,
+    transaction_type  VARCHAR(20)    NOT NULL,
+    created_at        TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transactions_from_wallet_id ON transactions (from_wallet_id);
+CREATE INDEX idx_transactions_to_wallet_id ON transactions (to_wallet_id);
+CREATE INDEX idx_transactions_created_at ON transactions (created_at);
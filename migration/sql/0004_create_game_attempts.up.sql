@@ -0,0 +1,7 @@
+CREATE TABLE game_attempts (
+    id         SERIAL PRIMARY KEY,
+    user_id    INT         NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+    attempts   INT         NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transactions_source_of_fund;
+DROP TABLE IF EXISTS sources_of_fund;
@@ -0,0 +1,21 @@
+CREATE TABLE sources_of_fund (
+    id         SERIAL PRIMARY KEY,
+    code       VARCHAR(32)    NOT NULL UNIQUE,
+    name       VARCHAR(100)   NOT NULL,
+    min_amount NUMERIC(15, 2) NOT NULL CHECK (min_amount > 0),
+    max_amount NUMERIC(15, 2) NOT NULL CHECK (max_amount >= min_amount),
+    is_active  BOOLEAN        NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO sources_of_fund (id, code, name, min_amount, max_amount) VALUES
+    (1, 'BANK_TRANSFER', 'Bank Transfer', 50000, 10000000),
+    (2, 'CREDIT_CARD', 'Credit Card', 50000, 10000000),
+    (3, 'CASH', 'Cash', 50000, 10000000),
+    (4, 'REWARD', 'Reward', 1, 10000000);
+
+SELECT setval('sources_of_fund_id_seq', (SELECT MAX(id) FROM sources_of_fund));
+
+ALTER TABLE transactions
+    ADD CONSTRAINT fk_transactions_source_of_fund
+        FOREIGN KEY (source_of_fund_id) REFERENCES sources_of_fund (id);
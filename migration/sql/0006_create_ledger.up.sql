@@ -0,0 +1,68 @@
+CREATE TABLE ledger_accounts (
+    id         SERIAL PRIMARY KEY,
+    code       VARCHAR(64)  NOT NULL UNIQUE,
+    name       VARCHAR(100) NOT NULL,
+    type       VARCHAR(16)  NOT NULL CHECK (type IN ('ASSET', 'LIABILITY', 'REVENUE', 'EXPENSE')),
+    wallet_id  INT UNIQUE REFERENCES wallets (id),
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ledger_accounts (code, name, type) VALUES
+    ('SYSTEM_FLOAT', 'System float', 'ASSET');
+
+INSERT INTO ledger_accounts (code, name, type, wallet_id)
+SELECT 'WALLET:' || wallet_number, 'Wallet ' || wallet_number, 'LIABILITY', id
+FROM wallets;
+
+CREATE TABLE journal_entries (
+    id             SERIAL PRIMARY KEY,
+    transaction_id INT REFERENCES transactions (id),
+    description    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_journal_entries_transaction_id ON journal_entries (transaction_id);
+
+-- Positive amounts are debits, negative amounts are credits.
+CREATE TABLE postings (
+    id               SERIAL PRIMARY KEY,
+    journal_entry_id INT            NOT NULL REFERENCES journal_entries (id),
+    account_id       INT            NOT NULL REFERENCES ledger_accounts (id),
+    amount           NUMERIC(15, 2) NOT NULL CHECK (amount <> 0),
+    created_at       TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_postings_journal_entry_id ON postings (journal_entry_id);
+CREATE INDEX idx_postings_account_id ON postings (account_id);
+
+-- Every journal entry must balance by the time its transaction commits.
+CREATE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM postings WHERE journal_entry_id = NEW.journal_entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.journal_entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+    AFTER INSERT OR UPDATE ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- Carry existing wallet balances over as a single opening entry.
+WITH entry AS (
+    INSERT INTO journal_entries (description)
+    SELECT 'Opening balance'
+    WHERE EXISTS (SELECT 1 FROM wallets WHERE balance <> 0)
+    RETURNING id
+)
+INSERT INTO postings (journal_entry_id, account_id, amount)
+SELECT entry.id, a.id, -w.balance
+FROM entry, wallets w
+JOIN ledger_accounts a ON a.wallet_id = w.id
+WHERE w.balance <> 0
+UNION ALL
+SELECT entry.id, (SELECT id FROM ledger_accounts WHERE code = 'SYSTEM_FLOAT'), SUM(w.balance)
+FROM entry, wallets w
+GROUP BY entry.id;
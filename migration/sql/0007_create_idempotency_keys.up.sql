@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys (
+    user_id       INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key           VARCHAR(255) NOT NULL,
+    request_hash  CHAR(64)     NOT NULL,
+    status_code   INT,
+    content_type  VARCHAR(255),
+    response_body BYTEA,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at  TIMESTAMPTZ,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at);
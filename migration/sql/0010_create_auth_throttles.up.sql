@@ -0,0 +1,22 @@
+CREATE TABLE auth_throttles (
+    scope          VARCHAR(32)  NOT NULL,
+    key            VARCHAR(255) NOT NULL,
+    failed_count   INT          NOT NULL DEFAULT 0,
+    locked_until   TIMESTAMPTZ,
+    last_failed_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE login_attempts (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    INT REFERENCES users (id) ON DELETE SET NULL,
+    email      VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45)  NOT NULL,
+    action     VARCHAR(32)  NOT NULL,
+    success    BOOLEAN      NOT NULL,
+    reason     VARCHAR(64)  NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_email_created_at ON login_attempts (email, created_at);
+CREATE INDEX idx_login_attempts_ip_address_created_at ON login_attempts (ip_address, created_at);
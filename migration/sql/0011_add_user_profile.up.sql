@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100),
+    ADD COLUMN avatar_url   VARCHAR(2048);
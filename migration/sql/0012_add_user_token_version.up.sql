@@ -0,0 +1,3 @@
+-- Access tokens carry the version they were issued at; bumping it
+-- invalidates every token issued before.
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
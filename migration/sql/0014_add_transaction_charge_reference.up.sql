@@ -0,0 +1,3 @@
+-- Top ups record the reference their external charge was made under, so it
+-- can be reconciled with the provider or refunded.
+ALTER TABLE transactions ADD COLUMN charge_reference VARCHAR(64) UNIQUE;
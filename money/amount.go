@@ -0,0 +1,248 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Amount is an exact monetary value stored as an integer number of minor
+// units (e.g. cents) of its currency. The zero value is zero in the
+// DefaultCurrency.
+type Amount struct {
+	minor    int64
+	currency Currency
+}
+
+// New returns an amount of the given number of minor units.
+func New(minor int64, currency Currency) Amount {
+	return Amount{minor: minor, currency: currency}
+}
+
+// FromMajor returns an amount of the given number of whole currency units.
+func FromMajor(major int64, currency Currency) Amount {
+	return Amount{minor: major * pow10(currency.Exponent()), currency: currency}
+}
+
+// Zero returns a zero amount in the given currency.
+func Zero(currency Currency) Amount {
+	return Amount{currency: currency}
+}
+
+// Parse reads a decimal string such as "1500", "-12.5" or "0.005". Digits
+// beyond the currency's exponent are rounded half away from zero.
+func Parse(s string, currency Currency) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if (whole == "" && frac == "") || (hasPoint && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return Amount{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	exp := currency.Exponent()
+	roundUp := false
+	if len(frac) > exp {
+		roundUp = frac[exp] >= '5'
+		frac = frac[:exp]
+	} else {
+		frac += strings.Repeat("0", exp-len(frac))
+	}
+
+	digits := strings.TrimLeft(whole+frac, "0")
+	var minor int64
+	if digits != "" {
+		var err error
+		minor, err = strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return Amount{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+		}
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return Amount{minor: minor, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is intended for
+// constants and configuration defaults.
+func MustParse(s string, currency Currency) Amount {
+	a, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// Minor returns the amount in minor units of its currency.
+func (a Amount) Minor() int64 {
+	return a.minor
+}
+
+func (a Amount) Currency() Currency {
+	if a.currency == "" {
+		return DefaultCurrency
+	}
+	return a.currency
+}
+
+func (a Amount) IsZero() bool     { return a.minor == 0 }
+func (a Amount) IsPositive() bool { return a.minor > 0 }
+func (a Amount) IsNegative() bool { return a.minor < 0 }
+
+func (a Amount) Neg() Amount {
+	return Amount{minor: -a.minor, currency: a.currency}
+}
+
+// Add returns a + b. It panics if the currencies differ.
+func (a Amount) Add(b Amount) Amount {
+	a.mustMatch(b)
+	return Amount{minor: a.minor + b.minor, currency: a.Currency()}
+}
+
+// Sub returns a - b. It panics if the currencies differ.
+func (a Amount) Sub(b Amount) Amount {
+	a.mustMatch(b)
+	return Amount{minor: a.minor - b.minor, currency: a.Currency()}
+}
+
+// Cmp returns -1, 0 or +1 depending on whether a is less than, equal to or
+// greater than b. It panics if the currencies differ.
+func (a Amount) Cmp(b Amount) int {
+	a.mustMatch(b)
+	switch {
+	case a.minor < b.minor:
+		return -1
+	case a.minor > b.minor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) LessThan(b Amount) bool    { return a.Cmp(b) < 0 }
+func (a Amount) GreaterThan(b Amount) bool { return a.Cmp(b) > 0 }
+
+// Sum adds up the amounts, which must all share one currency.
+func Sum(currency Currency, amounts ...Amount) Amount {
+	total := Zero(currency)
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+func (a Amount) mustMatch(b Amount) {
+	if a.Currency() != b.Currency() {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", a.Currency(), b.Currency()))
+	}
+}
+
+// String formats the amount as a plain decimal with exactly as many
+// fractional digits as the currency uses, e.g. "1500.00".
+func (a Amount) String() string {
+	exp := a.Currency().Exponent()
+	sign := ""
+	abs := uint64(a.minor)
+	if a.minor < 0 {
+		sign = "-"
+		abs = uint64(-a.minor)
+	}
+	if exp == 0 {
+		return sign + strconv.FormatUint(abs, 10)
+	}
+
+	p := uint64(pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/p, exp, abs%p)
+}
+
+// MarshalJSON encodes the amount as a JSON string so clients never see it
+// as a binary floating point number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts both a JSON string ("1500.50") and a JSON number
+// (1500.50); the number's literal text is parsed, never a float64.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(s, a.Currency())
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, sending the amount as decimal text that
+// PostgreSQL converts to NUMERIC without loss.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns.
+func (a *Amount) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*a = Zero(a.Currency())
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*a = FromMajor(v, a.Currency())
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+
+	parsed, err := Parse(s, a.Currency())
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,139 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRoundsHalfAwayFromZero(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency Currency
+		want     string
+	}{
+		{"1.005", USD, "1.01"},
+		{"1.004", USD, "1.00"},
+		{"-1.005", USD, "-1.01"},
+		{"-1.004", USD, "-1.00"},
+		{"0.005", IDR, "0.01"},
+		{"12.5", JPY, "13"},
+		{"-12.5", JPY, "-13"},
+		{"12.49", JPY, "12"},
+		{"1.0005", KWD, "1.001"},
+		{"1.0004", KWD, "1.000"},
+		{"+7", USD, "7.00"},
+		{".5", USD, "0.50"},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %s): %v", tt.in, tt.currency, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Parse(%q, %s) = %s, want %s", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidInput(t *testing.T) {
+	for _, in := range []string{"", "-", "1.", "abc", "1.2.3", "1e3", "99999999999999999999"} {
+		if _, err := Parse(in, USD); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestSumOfManySmallAmountsIsExact(t *testing.T) {
+	cent := MustParse("0.01", USD)
+	amounts := make([]Amount, 1_000_000)
+	for i := range amounts {
+		amounts[i] = cent
+	}
+
+	got := Sum(USD, amounts...)
+	if got.String() != "10000.00" {
+		t.Fatalf("Sum = %s, want 10000.00", got)
+	}
+	if got.Minor() != 1_000_000 {
+		t.Fatalf("Sum.Minor() = %d, want 1000000", got.Minor())
+	}
+}
+
+func TestSumPanicsOnMixedCurrencies(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Sum of mixed currencies did not panic")
+		}
+	}()
+	Sum(USD, MustParse("1", USD), MustParse("1", SGD))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	want := MustParse("1500.50", DefaultCurrency)
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"1500.50"` {
+		t.Fatalf("Marshal = %s, want \"1500.50\"", data)
+	}
+
+	var got Amount
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(want) != 0 {
+		t.Fatalf("round trip = %s, want %s", got, want)
+	}
+}
+
+func TestUnmarshalJSONNumberKeepsLiteral(t *testing.T) {
+	var got Amount
+	if err := json.Unmarshal([]byte(`0.1`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Minor() != 10 {
+		t.Fatalf("Minor() = %d, want 10", got.Minor())
+	}
+}
+
+func TestValueAndScan(t *testing.T) {
+	amount := MustParse("-42.07", DefaultCurrency)
+
+	value, err := amount.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "-42.07" {
+		t.Fatalf("Value = %v, want -42.07", value)
+	}
+
+	tests := []struct {
+		src  interface{}
+		want string
+	}{
+		{[]byte("-42.07"), "-42.07"},
+		{"19.99", "19.99"},
+		{int64(5), "5.00"},
+		{float64(0.3), "0.30"},
+		{nil, "0.00"},
+	}
+	for _, tt := range tests {
+		var got Amount
+		if err := got.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Scan(%#v) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+
+	var got Amount
+	if err := got.Scan(true); err == nil {
+		t.Error("Scan(bool) succeeded, want error")
+	}
+}
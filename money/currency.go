@@ -0,0 +1,38 @@
+package money
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	IDR Currency = "IDR"
+	USD Currency = "USD"
+	SGD Currency = "SGD"
+	JPY Currency = "JPY"
+	KWD Currency = "KWD"
+)
+
+// DefaultCurrency is the currency of every wallet and of amounts read from
+// the database, which stores plain NUMERIC(15, 2) columns.
+const DefaultCurrency = IDR
+
+// exponents holds the number of minor-unit digits for each currency.
+var exponents = map[Currency]int{
+	IDR: 2,
+	USD: 2,
+	SGD: 2,
+	JPY: 0,
+	KWD: 3,
+}
+
+// Exponent returns the number of digits after the decimal point used by the
+// currency. Unknown currencies default to two.
+func (c Currency) Exponent() int {
+	if exp, ok := exponents[c]; ok {
+		return exp
+	}
+	return 2
+}
+
+func (c Currency) String() string {
+	return string(c)
+}
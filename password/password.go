@@ -0,0 +1,189 @@
+// Package password checks new passwords against a configurable policy.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rule names reported in violations.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RulePersonalInfo = "personal_info"
+	RuleCommon       = "common"
+)
+
+// bcryptMaxLength is the number of bytes bcrypt looks at; anything past it
+// is silently ignored, so longer passwords are rejected instead.
+const bcryptMaxLength = 72
+
+// minPersonalInfoLength is the shortest username or email part that is
+// looked for inside passwords; shorter ones match too much by accident.
+const minPersonalInfoLength = 3
+
+//go:embed common.txt
+var commonFile string
+
+var common = loadCommon(commonFile)
+
+func loadCommon(content string) map[string]struct{} {
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}
+
+type Policy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// RejectPersonalInfo rejects passwords containing the username or email.
+	RejectPersonalInfo bool
+	// RejectCommon rejects passwords on the bundled list of common and
+	// breached passwords.
+	RejectCommon bool
+}
+
+// DefaultPolicy is used unless configured otherwise.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:          8,
+		MaxLength:          bcryptMaxLength,
+		RequireUppercase:   true,
+		RequireLowercase:   true,
+		RequireDigit:       true,
+		RejectPersonalInfo: true,
+		RejectCommon:       true,
+	}
+}
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// Validate checks password against the policy. personalInfo holds the
+// user's username and email, which must not appear in the password. It
+// returns a *PolicyError listing every violation, or nil.
+func (p Policy) Validate(password string, personalInfo ...string) error {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if length := len([]rune(password)); length < p.MinLength {
+		add(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > bcryptMaxLength {
+		maxLength = bcryptMaxLength
+	}
+	if len(password) > maxLength {
+		add(RuleMaxLength, "must be at most %d bytes long", maxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		add(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		add(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "must contain a symbol")
+	}
+
+	if p.RejectPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		add(RulePersonalInfo, "must not contain your username or email")
+	}
+
+	if p.RejectCommon && isCommon(password) {
+		add(RuleCommon, "is too common")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lowered := strings.ToLower(password)
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		parts := []string{info}
+		if local, _, ok := strings.Cut(info, "@"); ok {
+			parts = append(parts, local)
+		}
+
+		for _, part := range parts {
+			if len(part) >= minPersonalInfoLength && strings.Contains(lowered, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCommon also catches common passwords decorated with trailing digits or
+// symbols, such as "Password123!".
+func isCommon(password string) bool {
+	lowered := strings.ToLower(password)
+	if _, ok := common[lowered]; ok {
+		return true
+	}
+
+	trimmed := strings.TrimRightFunc(lowered, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if trimmed == "" || trimmed == lowered {
+		return false
+	}
+	_, ok := common[trimmed]
+	return ok
+}
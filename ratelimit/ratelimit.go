@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Period. Requests are metered with a token
+// bucket holding up to Limit tokens that refills evenly over Period, so
+// short bursts are allowed while the long-run rate is capped.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled reports whether the policy limits anything.
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%s", p.Limit, p.Period)
+}
+
+// Result describes the state of a bucket after a request was metered.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request would be allowed; zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps one bucket per key. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", e.g. "10/1m".
+func ParsePolicy(value string) (Policy, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit %q, expected <limit>/<period>", value)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: bad limit", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: bad period", value)
+	}
+
+	return Policy{Limit: n, Period: d}, nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"main/entity"
+	"main/tracing"
+	"time"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+type IdempotencyRepository interface {
+	CreateIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(ctx context.Context, userID int, key string) (*entity.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, userID int, key string) error
+	DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &idempotencyRepositoryImpl{db: db}
+}
+
+// CreateIdempotencyKey reserves the key for the user. It reports false,
+// without error, when the key has already been reserved.
+func (r *idempotencyRepositoryImpl) CreateIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) (bool, error) {
+	query := `
+        INSERT INTO idempotency_keys (user_id, key, request_hash, created_at)
+        VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+        ON CONFLICT (user_id, key) DO NOTHING
+        RETURNING created_at`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "IdempotencyRepository.CreateIdempotencyKey"), query, key.UserID, key.Key, key.RequestHash).Scan(&key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *idempotencyRepositoryImpl) GetIdempotencyKey(ctx context.Context, userID int, key string) (*entity.IdempotencyKey, error) {
+	record := &entity.IdempotencyKey{}
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	query := `
+        SELECT user_id, key, request_hash, status_code, content_type,
+               response_body, created_at, completed_at
+        FROM idempotency_keys
+        WHERE user_id = $1 AND key = $2`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "IdempotencyRepository.GetIdempotencyKey"), query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&contentType,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.CompletedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.StatusCode = int(statusCode.Int64)
+	record.ContentType = contentType.String
+	return record, nil
+}
+
+func (r *idempotencyRepositoryImpl) CompleteIdempotencyKey(ctx context.Context, key *entity.IdempotencyKey) error {
+	query := `
+        UPDATE idempotency_keys
+        SET status_code = $1,
+            content_type = $2,
+            response_body = $3,
+            completed_at = CURRENT_TIMESTAMP
+        WHERE user_id = $4 AND key = $5`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "IdempotencyRepository.CompleteIdempotencyKey"), query,
+		key.StatusCode,
+		key.ContentType,
+		key.ResponseBody,
+		key.UserID,
+		key.Key,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrIdempotencyKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *idempotencyRepositoryImpl) DeleteIdempotencyKey(ctx context.Context, userID int, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "IdempotencyRepository.DeleteIdempotencyKey"), query, userID, key)
+	return err
+}
+
+func (r *idempotencyRepositoryImpl) DeleteIdempotencyKeysBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE created_at < $1`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "IdempotencyRepository.DeleteIdempotencyKeysBefore"), query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"main/entity"
+	"main/ledger"
+	"main/money"
+	"main/tracing"
+)
+
+var ErrLedgerAccountNotFound = errors.New("ledger account not found")
+
+type LedgerRepository interface {
+	GetAccountByCode(ctx context.Context, code string) (*entity.LedgerAccount, error)
+	GetWalletAccount(ctx context.Context, wallet *entity.Wallet) (*entity.LedgerAccount, error)
+	CreateJournalEntry(ctx context.Context, entry *entity.JournalEntry) error
+	GetAccountBalance(ctx context.Context, account *entity.LedgerAccount) (money.Amount, error)
+	GetTrialBalance(ctx context.Context) (money.Amount, error)
+	ListWalletBalanceMismatches(ctx context.Context) ([]entity.WalletBalanceMismatch, error)
+}
+
+type ledgerRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewLedgerRepository(db *sql.DB) LedgerRepository {
+	return &ledgerRepositoryImpl{db: db}
+}
+
+func (r *ledgerRepositoryImpl) GetAccountByCode(ctx context.Context, code string) (*entity.LedgerAccount, error) {
+	account := &entity.LedgerAccount{}
+	query := `
+        SELECT id, code, name, type, wallet_id, created_at
+        FROM ledger_accounts
+        WHERE code = $1`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "LedgerRepository.GetAccountByCode"), query, code).Scan(
+		&account.ID,
+		&account.Code,
+		&account.Name,
+		&account.Type,
+		&account.WalletID,
+		&account.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrLedgerAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// GetWalletAccount returns the liability account backing the wallet,
+// opening it on first use.
+func (r *ledgerRepositoryImpl) GetWalletAccount(ctx context.Context, wallet *entity.Wallet) (*entity.LedgerAccount, error) {
+	query := `
+        INSERT INTO ledger_accounts (code, name, type, wallet_id)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (wallet_id) DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "LedgerRepository.GetWalletAccount"), query,
+		"WALLET:"+wallet.WalletNumber,
+		"Wallet "+wallet.WalletNumber,
+		entity.LedgerAccountTypeLiability,
+		wallet.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetAccountByCode(ctx, "WALLET:"+wallet.WalletNumber)
+}
+
+func (r *ledgerRepositoryImpl) CreateJournalEntry(ctx context.Context, entry *entity.JournalEntry) error {
+	db := conn(ctx, r.db)
+
+	query := `
+        INSERT INTO journal_entries (transaction_id, description, created_at)
+        VALUES ($1, $2, CURRENT_TIMESTAMP)
+        RETURNING id, created_at`
+
+	err := db.QueryRowContext(tracing.WithQueryName(ctx, "LedgerRepository.CreateJournalEntry"), query, entry.TransactionID, entry.Description).
+		Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	postingQuery := `
+        INSERT INTO postings (journal_entry_id, account_id, amount)
+        VALUES ($1, $2, $3)
+        RETURNING id`
+
+	for i := range entry.Postings {
+		p := &entry.Postings[i]
+		err := db.QueryRowContext(tracing.WithQueryName(ctx, "LedgerRepository.CreatePosting"), postingQuery, entry.ID, p.AccountID, p.Amount).Scan(&p.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAccountBalance derives the account's balance from its postings.
+func (r *ledgerRepositoryImpl) GetAccountBalance(ctx context.Context, account *entity.LedgerAccount) (money.Amount, error) {
+	var sum money.Amount
+	query := `SELECT COALESCE(SUM(amount), 0) FROM postings WHERE account_id = $1`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "LedgerRepository.GetAccountBalance"), query, account.ID).Scan(&sum)
+	if err != nil {
+		return money.Amount{}, err
+	}
+
+	return ledger.NormalBalance(account.Type, sum), nil
+}
+
+// GetTrialBalance sums every posting in the ledger, which must be zero.
+func (r *ledgerRepositoryImpl) GetTrialBalance(ctx context.Context) (money.Amount, error) {
+	var sum money.Amount
+	query := `SELECT COALESCE(SUM(amount), 0) FROM postings`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "LedgerRepository.GetTrialBalance"), query).Scan(&sum)
+	return sum, err
+}
+
+// ListWalletBalanceMismatches cross-checks wallets.balance against the
+// balance derived from each wallet's ledger account.
+func (r *ledgerRepositoryImpl) ListWalletBalanceMismatches(ctx context.Context) ([]entity.WalletBalanceMismatch, error) {
+	query := `
+        SELECT w.id, w.wallet_number, w.balance,
+               -COALESCE(SUM(p.amount), 0) AS ledger_balance
+        FROM wallets w
+        LEFT JOIN ledger_accounts a ON a.wallet_id = w.id
+        LEFT JOIN postings p ON p.account_id = a.id
+        GROUP BY w.id, w.wallet_number, w.balance
+        HAVING w.balance <> -COALESCE(SUM(p.amount), 0)
+        ORDER BY w.id`
+
+	var mismatches []entity.WalletBalanceMismatch
+	err := queryRows(tracing.WithQueryName(ctx, "LedgerRepository.ListWalletBalanceMismatches"), conn(ctx, r.db), query, nil, func(rows *sql.Rows) error {
+		var m entity.WalletBalanceMismatch
+		if err := rows.Scan(&m.WalletID, &m.WalletNumber, &m.StoredBalance, &m.LedgerBalance); err != nil {
+			return err
+		}
+		mismatches = append(mismatches, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
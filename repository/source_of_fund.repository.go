@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"main/entity"
+	"main/tracing"
+)
+
+var ErrSourceOfFundNotFound = errors.New("source of fund not found")
+
+type SourceOfFundRepository interface {
+	ListSourcesOfFund(ctx context.Context) ([]entity.SourceOfFund, error)
+	GetSourceOfFundByID(ctx context.Context, id int) (*entity.SourceOfFund, error)
+}
+
+type sourceOfFundRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewSourceOfFundRepository(db *sql.DB) SourceOfFundRepository {
+	return &sourceOfFundRepositoryImpl{db: db}
+}
+
+func (r *sourceOfFundRepositoryImpl) ListSourcesOfFund(ctx context.Context) ([]entity.SourceOfFund, error) {
+	query := `
+        SELECT id, code, name, min_amount, max_amount
+        FROM sources_of_fund
+        WHERE is_active
+        ORDER BY id`
+
+	var sources []entity.SourceOfFund
+	err := queryRows(tracing.WithQueryName(ctx, "SourceOfFundRepository.ListSourcesOfFund"), conn(ctx, r.db), query, nil, func(rows *sql.Rows) error {
+		var s entity.SourceOfFund
+		if err := rows.Scan(&s.ID, &s.Code, &s.Name, &s.MinAmount, &s.MaxAmount); err != nil {
+			return err
+		}
+		sources = append(sources, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+func (r *sourceOfFundRepositoryImpl) GetSourceOfFundByID(ctx context.Context, id int) (*entity.SourceOfFund, error) {
+	source := &entity.SourceOfFund{}
+	query := `
+        SELECT id, code, name, min_amount, max_amount
+        FROM sources_of_fund
+        WHERE id = $1 AND is_active`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "SourceOfFundRepository.GetSourceOfFundByID"), query, id).Scan(
+		&source.ID,
+		&source.Code,
+		&source.Name,
+		&source.MinAmount,
+		&source.MaxAmount,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSourceOfFundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"main/entity"
+	"main/tracing"
+	"time"
+)
+
+type ThrottleRepository interface {
+	GetThrottle(ctx context.Context, scope, key string) (*entity.AuthThrottle, error)
+	RecordFailure(ctx context.Context, scope, key string, resetAfter time.Duration) (*entity.AuthThrottle, error)
+	LockThrottle(ctx context.Context, scope, key string, until time.Time) error
+	ResetThrottle(ctx context.Context, scope, key string) error
+	CreateLoginAttempt(ctx context.Context, attempt *entity.LoginAttempt) error
+	DeleteStaleThrottles(ctx context.Context, before time.Time) (int64, error)
+}
+
+type throttleRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewThrottleRepository(db *sql.DB) ThrottleRepository {
+	return &throttleRepositoryImpl{db: db}
+}
+
+// GetThrottle returns a zero throttle, not an error, for keys with no
+// recorded failures.
+func (r *throttleRepositoryImpl) GetThrottle(ctx context.Context, scope, key string) (*entity.AuthThrottle, error) {
+	throttle := &entity.AuthThrottle{Scope: scope, Key: key}
+	query := `
+        SELECT failed_count, locked_until, last_failed_at
+        FROM auth_throttles
+        WHERE scope = $1 AND key = $2`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "ThrottleRepository.GetThrottle"), query, scope, key).Scan(
+		&throttle.FailedCount,
+		&throttle.LockedUntil,
+		&throttle.LastFailedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return throttle, nil
+}
+
+// RecordFailure counts a failed attempt. The count starts over when the
+// previous failure is older than resetAfter.
+func (r *throttleRepositoryImpl) RecordFailure(ctx context.Context, scope, key string, resetAfter time.Duration) (*entity.AuthThrottle, error) {
+	throttle := &entity.AuthThrottle{Scope: scope, Key: key}
+	query := `
+        INSERT INTO auth_throttles (scope, key, failed_count, last_failed_at)
+        VALUES ($1, $2, 1, CURRENT_TIMESTAMP)
+        ON CONFLICT (scope, key) DO UPDATE
+        SET failed_count = CASE
+                WHEN auth_throttles.last_failed_at < CURRENT_TIMESTAMP - $3::float8 * INTERVAL '1 second' THEN 1
+                ELSE auth_throttles.failed_count + 1
+            END,
+            last_failed_at = CURRENT_TIMESTAMP
+        RETURNING failed_count, locked_until, last_failed_at`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "ThrottleRepository.RecordFailure"), query, scope, key, resetAfter.Seconds()).Scan(
+		&throttle.FailedCount,
+		&throttle.LockedUntil,
+		&throttle.LastFailedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle, nil
+}
+
+func (r *throttleRepositoryImpl) LockThrottle(ctx context.Context, scope, key string, until time.Time) error {
+	query := `
+        UPDATE auth_throttles
+        SET locked_until = $1
+        WHERE scope = $2 AND key = $3`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "ThrottleRepository.LockThrottle"), query, until, scope, key)
+	return err
+}
+
+func (r *throttleRepositoryImpl) ResetThrottle(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM auth_throttles WHERE scope = $1 AND key = $2`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "ThrottleRepository.ResetThrottle"), query, scope, key)
+	return err
+}
+
+func (r *throttleRepositoryImpl) CreateLoginAttempt(ctx context.Context, attempt *entity.LoginAttempt) error {
+	query := `
+        INSERT INTO login_attempts (user_id, email, ip_address, action, success, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+        RETURNING id, created_at`
+
+	return conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "ThrottleRepository.CreateLoginAttempt"), query,
+		attempt.UserID,
+		attempt.Email,
+		attempt.IPAddress,
+		attempt.Action,
+		attempt.Success,
+		attempt.Reason,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+}
+
+// DeleteStaleThrottles removes counters whose last failure is older than
+// before and that are not locked.
+func (r *throttleRepositoryImpl) DeleteStaleThrottles(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+        DELETE FROM auth_throttles
+        WHERE last_failed_at < $1
+          AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "ThrottleRepository.DeleteStaleThrottles"), query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"main/entity"
+	"main/tracing"
+	"time"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	RevokeAccessToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+type tokenRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) TokenRepository {
+	return &tokenRepositoryImpl{db: db}
+}
+
+func (r *tokenRepositoryImpl) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	query := `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+        RETURNING id, created_at`
+
+	return conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TokenRepository.CreateRefreshToken"), query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (r *tokenRepositoryImpl) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	token := &entity.RefreshToken{}
+	query := `
+        SELECT id, user_id, family_id, token_hash, expires_at,
+               used_at, revoked_at, created_at
+        FROM refresh_tokens
+        WHERE token_hash = $1`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TokenRepository.GetRefreshTokenByHash"), query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkRefreshTokenUsed consumes the token. It reports false when the token
+// had already been used, which means it is being replayed.
+func (r *tokenRepositoryImpl) MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error) {
+	query := `
+        UPDATE refresh_tokens
+        SET used_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND used_at IS NULL`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TokenRepository.MarkRefreshTokenUsed"), query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func (r *tokenRepositoryImpl) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = CURRENT_TIMESTAMP
+        WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TokenRepository.RevokeRefreshTokenFamily"), query, familyID)
+	return err
+}
+
+func (r *tokenRepositoryImpl) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TokenRepository.RevokeUserRefreshTokens"), query, userID)
+	return err
+}
+
+func (r *tokenRepositoryImpl) RevokeAccessToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	query := `
+        INSERT INTO revoked_access_tokens (jti, user_id, expires_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (jti) DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TokenRepository.RevokeAccessToken"), query, jti, userID, expiresAt)
+	return err
+}
+
+func (r *tokenRepositoryImpl) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TokenRepository.IsAccessTokenRevoked"), query, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// DeleteExpiredTokens removes refresh tokens and denylist entries that
+// expired before the given time; they can no longer be used either way.
+func (r *tokenRepositoryImpl) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM refresh_tokens WHERE expires_at < $1`,
+		`DELETE FROM revoked_access_tokens WHERE expires_at < $1`,
+	} {
+		result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TokenRepository.DeleteExpiredTokens"), query, before)
+		if err != nil {
+			return deleted, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += rows
+	}
+	return deleted, nil
+}
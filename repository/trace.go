@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"main/tracing"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("main/repository")
+
+// tracedDBTX records a client span for every statement, named after the
+// query name carried by its context (see tracing.WithQueryName).
+type tracedDBTX struct {
+	db DBTX
+}
+
+func (t tracedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	result, err := t.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", n))
+	}
+	return result, nil
+}
+
+// QueryContext times the query itself; multi-row reads that want their row
+// count recorded go through queryRows instead.
+func (t tracedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (t tracedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	row := t.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+// queryRows runs a multi-row query and calls scan for each row, recording
+// the number of rows read on the query's span.
+func queryRows(ctx context.Context, db DBTX, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	if traced, ok := db.(tracedDBTX); ok {
+		db = traced.db
+	}
+
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			recordError(span, err)
+			return err
+		}
+		count++
+	}
+	span.SetAttributes(attribute.Int("db.rows", count))
+
+	err = rows.Err()
+	recordError(span, err)
+	return err
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := operation(query)
+	name := tracing.QueryName(ctx)
+	if name == "" {
+		name = operation
+	}
+
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.query.name", name),
+			attribute.String("db.operation", operation),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// operation returns the SQL statement's leading keyword.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
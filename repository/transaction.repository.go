@@ -3,15 +3,21 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"main/dto"
 	"main/entity"
+	"main/tracing"
 	"strings"
 	"time"
 )
 
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 type TransactionRepository interface {
 	ListTransactions(ctx context.Context, userID int, req dto.TransactionListRequest) ([]entity.Transaction, int, error)
+	GetTransactionByID(ctx context.Context, userID, id int) (*entity.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction *entity.Transaction) error
 }
 
 type transactionRepoImpl struct {
@@ -28,7 +34,7 @@ func (r *transactionRepoImpl) ListTransactions(ctx context.Context, userID int,
         SELECT 
             t.id, t.from_wallet_id, t.to_wallet_id, t.amount, 
             t.description, t.source_of_fund_id, t.transaction_type, 
-            t.created_at,
+            t.charge_reference, t.created_at,
             fw.wallet_number as from_wallet_number,
             tw.wallet_number as to_wallet_number,
             u.username as recipient_name
@@ -105,36 +111,93 @@ func (r *transactionRepoImpl) ListTransactions(ctx context.Context, userID int,
 
 	// Get total count
 	var totalItems int
-	err := r.db.QueryRowContext(ctx, countQuery, params...).Scan(&totalItems)
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TransactionRepository.CountTransactions"), countQuery, params...).Scan(&totalItems)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Execute the main query
-	rows, err := r.db.QueryContext(ctx, baseQuery, params...)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
 	var transactions []entity.Transaction
-	for rows.Next() {
+	err = queryRows(tracing.WithQueryName(ctx, "TransactionRepository.ListTransactions"), conn(ctx, r.db), baseQuery, params, func(rows *sql.Rows) error {
 		var t entity.Transaction
-		var fromWalletID, fromWalletNumber sql.NullString
+		var fromWalletNumber sql.NullString
 		err := rows.Scan(
-			&t.ID, &fromWalletID, &t.ToWalletID, &t.Amount,
+			&t.ID, &t.FromWalletID, &t.ToWalletID, &t.Amount,
 			&t.Description, &t.SourceOfFundID, &t.TransactionType,
-			&t.CreatedAt, &fromWalletNumber, &t.ToWalletNumber,
+			&t.ChargeReference, &t.CreatedAt, &fromWalletNumber, &t.ToWalletNumber,
 			&t.RecipientName,
 		)
 		if err != nil {
-			return nil, 0, err
+			return err
 		}
 		if fromWalletNumber.Valid {
 			t.FromWalletNumber = fromWalletNumber.String
 		}
 		transactions = append(transactions, t)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 
 	return transactions, totalItems, nil
 }
+
+// GetTransactionByID only returns transactions where the user owns the
+// sending or the receiving wallet; any other ID is reported as not found.
+func (r *transactionRepoImpl) GetTransactionByID(ctx context.Context, userID, id int) (*entity.Transaction, error) {
+	query := `
+        SELECT 
+            t.id, t.from_wallet_id, t.to_wallet_id, t.amount, 
+            t.description, t.source_of_fund_id, t.transaction_type, 
+            t.charge_reference, t.created_at,
+            fw.wallet_number as from_wallet_number,
+            tw.wallet_number as to_wallet_number,
+            u.username as recipient_name
+        FROM transactions t
+        LEFT JOIN wallets fw ON t.from_wallet_id = fw.id
+        JOIN wallets tw ON t.to_wallet_id = tw.id
+        JOIN users u ON tw.user_id = u.id
+        WHERE t.id = $1 AND (fw.user_id = $2 OR tw.user_id = $2)`
+
+	var t entity.Transaction
+	var fromWalletNumber sql.NullString
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TransactionRepository.GetTransactionByID"), query, id, userID).Scan(
+		&t.ID, &t.FromWalletID, &t.ToWalletID, &t.Amount,
+		&t.Description, &t.SourceOfFundID, &t.TransactionType,
+		&t.ChargeReference, &t.CreatedAt, &fromWalletNumber, &t.ToWalletNumber,
+		&t.RecipientName,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fromWalletNumber.Valid {
+		t.FromWalletNumber = fromWalletNumber.String
+	}
+
+	return &t, nil
+}
+
+func (r *transactionRepoImpl) CreateTransaction(ctx context.Context, transaction *entity.Transaction) error {
+	query := `
+        INSERT INTO transactions (
+            from_wallet_id, to_wallet_id, amount, description,
+            source_of_fund_id, transaction_type, charge_reference, created_at
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+        RETURNING id, created_at`
+
+	return conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "TransactionRepository.CreateTransaction"), query,
+		transaction.FromWalletID,
+		transaction.ToWalletID,
+		transaction.Amount,
+		transaction.Description,
+		transaction.SourceOfFundID,
+		transaction.TransactionType,
+		transaction.ChargeReference,
+	).Scan(&transaction.ID, &transaction.CreatedAt)
+}
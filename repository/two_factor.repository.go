@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"main/tracing"
+)
+
+type TwoFactorRepository interface {
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+type twoFactorRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) TwoFactorRepository {
+	return &twoFactorRepositoryImpl{db: db}
+}
+
+// SetTOTPSecret stores a pending secret; it only takes effect once
+// EnableTOTP is called.
+func (r *twoFactorRepositoryImpl) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `
+        UPDATE users
+        SET totp_secret = $1,
+            totp_enabled_at = NULL,
+            totp_last_step = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2`
+
+	return r.exec(tracing.WithQueryName(ctx, "TwoFactorRepository.SetTOTPSecret"), query, secret, userID)
+}
+
+func (r *twoFactorRepositoryImpl) EnableTOTP(ctx context.Context, userID int) error {
+	query := `
+        UPDATE users
+        SET totp_enabled_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND totp_secret IS NOT NULL`
+
+	return r.exec(tracing.WithQueryName(ctx, "TwoFactorRepository.EnableTOTP"), query, userID)
+}
+
+func (r *twoFactorRepositoryImpl) DisableTOTP(ctx context.Context, userID int) error {
+	query := `
+        UPDATE users
+        SET totp_secret = NULL,
+            totp_enabled_at = NULL,
+            totp_last_step = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1`
+
+	if err := r.exec(tracing.WithQueryName(ctx, "TwoFactorRepository.DisableTOTP"), query, userID); err != nil {
+		return err
+	}
+
+	_, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TwoFactorRepository.DisableTOTP"), `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
+
+// UseTOTPStep records the time step of an accepted code. It reports false
+// when that step, or a later one, was already used, so a code cannot be
+// replayed within its validity window.
+func (r *twoFactorRepositoryImpl) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `
+        UPDATE users
+        SET totp_last_step = $1
+        WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TwoFactorRepository.UseTOTPStep"), query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func (r *twoFactorRepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	db := conn(ctx, r.db)
+	ctx = tracing.WithQueryName(ctx, "TwoFactorRepository.ReplaceRecoveryCodes")
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, hash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes a recovery code, reporting false if it does not
+// exist or was already used.
+func (r *twoFactorRepositoryImpl) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `
+        UPDATE recovery_codes
+        SET used_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "TwoFactorRepository.UseRecoveryCode"), query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// The caller names the query in ctx with tracing.WithQueryName.
+func (r *twoFactorRepositoryImpl) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
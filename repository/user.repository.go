@@ -5,14 +5,30 @@ import (
 	"database/sql"
 	"errors"
 	"main/entity"
+	"main/tracing"
 	"time"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserModified means the user changed since the caller read it.
+	ErrUserModified = errors.New("user was modified by another request")
+	// ErrEmailTaken means another user already has the email address.
+	ErrEmailTaken = errors.New("email already registered")
+)
+
+// emailUniqueConstraint is the unique constraint on users.email.
+const emailUniqueConstraint = "users_email_key"
+
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *entity.User) error
 	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
-	UpdateResetPasswordCode(ctx context.Context, email, code string) error
+	GetUserByID(ctx context.Context, id int) (*entity.User, error)
+	UpdateUser(ctx context.Context, user *entity.User) error
+	UpdateResetPasswordCode(ctx context.Context, email, codeHash string, expiresAt time.Time) error
 	UpdatePassword(ctx context.Context, email, passwordHash string) error
+	GetTokenVersion(ctx context.Context, id int) (int, error)
+	MarkEmailVerified(ctx context.Context, id int, email string) error
 }
 
 type userRepositoryImpl struct {
@@ -23,18 +39,24 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepositoryImpl{db: db}
 }
 
+// CreateUser inserts the user with an empty wallet and game attempts. Call
+// it within a transaction so a user is never left without a wallet.
 func (r *userRepositoryImpl) CreateUser(ctx context.Context, user *entity.User) error {
 	query := `
         INSERT INTO users (username, email, password_hash, created_at, updated_at)
         VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
         RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRowContext(ctx, query,
+	db := conn(ctx, r.db)
+	err := db.QueryRowContext(tracing.WithQueryName(ctx, "UserRepository.CreateUser"), query,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
+	if isUniqueViolation(err, emailUniqueConstraint) {
+		return ErrEmailTaken
+	}
 	if err != nil {
 		return err
 	}
@@ -48,48 +70,52 @@ func (r *userRepositoryImpl) CreateUser(ctx context.Context, user *entity.User)
         INSERT INTO game_attempts (user_id, attempts)
         VALUES ($1, 0)`
 
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	_, err = tx.ExecContext(ctx, walletQuery, user.ID)
-	if err != nil {
-		tx.Rollback()
+	if _, err := db.ExecContext(tracing.WithQueryName(ctx, "UserRepository.CreateWallet"), walletQuery, user.ID); err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, gameQuery, user.ID)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	return tx.Commit()
+	_, err = db.ExecContext(tracing.WithQueryName(ctx, "UserRepository.CreateGameAttempts"), gameQuery, user.ID)
+	return err
 }
 
-func (r *userRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
-	user := &entity.User{}
-	query := `
-        SELECT id, username, email, password_hash, 
+const userSelect = `
+        SELECT id, username, email, email_verified_at, password_hash,
                reset_password_code, reset_password_code_expiry,
+               totp_secret, totp_enabled_at,
+               display_name, avatar_url, token_version,
                created_at, updated_at
-        FROM users
-        WHERE email = $1`
+        FROM users`
+
+func (r *userRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return r.getUser(tracing.WithQueryName(ctx, "UserRepository.GetUserByEmail"), userSelect+` WHERE email = $1`, email)
+}
+
+func (r *userRepositoryImpl) GetUserByID(ctx context.Context, id int) (*entity.User, error) {
+	return r.getUser(tracing.WithQueryName(ctx, "UserRepository.GetUserByID"), userSelect+` WHERE id = $1`, id)
+}
 
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
+// The caller names the query in ctx with tracing.WithQueryName.
+func (r *userRepositoryImpl) getUser(ctx context.Context, query string, arg interface{}) (*entity.User, error) {
+	user := &entity.User{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, arg).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.EmailVerifiedAt,
 		&user.PasswordHash,
 		&user.ResetPasswordCode,
 		&user.ResetPasswordExpiry,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
+		&user.DisplayName,
+		&user.AvatarURL,
+		&user.TokenVersion,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -98,7 +124,44 @@ func (r *userRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (
 	return user, nil
 }
 
-func (r *userRepositoryImpl) UpdateResetPasswordCode(ctx context.Context, email, code string) error {
+// UpdateUser saves the user's profile fields. The update only applies if
+// user.UpdatedAt still matches the stored row, otherwise ErrUserModified is
+// returned; on success user.UpdatedAt is refreshed. Changing the email
+// clears its verification.
+func (r *userRepositoryImpl) UpdateUser(ctx context.Context, user *entity.User) error {
+	query := `
+        UPDATE users
+        SET username = $1,
+            email = $2,
+            email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+            display_name = $3,
+            avatar_url = $4,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5 AND updated_at = $6
+        RETURNING email_verified_at, updated_at`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "UserRepository.UpdateUser"), query,
+		user.Username,
+		user.Email,
+		user.DisplayName,
+		user.AvatarURL,
+		user.ID,
+		user.UpdatedAt,
+	).Scan(&user.EmailVerifiedAt, &user.UpdatedAt)
+
+	if isUniqueViolation(err, emailUniqueConstraint) {
+		return ErrEmailTaken
+	}
+	if err == sql.ErrNoRows {
+		if _, err := r.GetUserByID(ctx, user.ID); err != nil {
+			return err
+		}
+		return ErrUserModified
+	}
+	return err
+}
+
+func (r *userRepositoryImpl) UpdateResetPasswordCode(ctx context.Context, email, codeHash string, expiresAt time.Time) error {
 	query := `
         UPDATE users 
         SET reset_password_code = $1,
@@ -106,8 +169,7 @@ func (r *userRepositoryImpl) UpdateResetPasswordCode(ctx context.Context, email,
             updated_at = CURRENT_TIMESTAMP
         WHERE email = $3`
 
-	expiry := time.Now().Add(15 * time.Minute)
-	result, err := r.db.ExecContext(ctx, query, code, expiry, email)
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "UserRepository.UpdateResetPasswordCode"), query, codeHash, expiresAt, email)
 	if err != nil {
 		return err
 	}
@@ -117,22 +179,61 @@ func (r *userRepositoryImpl) UpdateResetPasswordCode(ctx context.Context, email,
 		return err
 	}
 	if rows == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
+// UpdatePassword sets a new password hash and bumps the token version, which
+// invalidates every access token issued before.
 func (r *userRepositoryImpl) UpdatePassword(ctx context.Context, email, passwordHash string) error {
 	query := `
         UPDATE users 
         SET password_hash = $1,
             reset_password_code = NULL,
             reset_password_code_expiry = NULL,
+            token_version = token_version + 1,
             updated_at = CURRENT_TIMESTAMP
         WHERE email = $2`
 
-	result, err := r.db.ExecContext(ctx, query, passwordHash, email)
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "UserRepository.UpdatePassword"), query, passwordHash, email)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepositoryImpl) GetTokenVersion(ctx context.Context, id int) (int, error) {
+	var version int
+	query := `SELECT token_version FROM users WHERE id = $1`
+
+	err := conn(ctx, r.db).QueryRowContext(tracing.WithQueryName(ctx, "UserRepository.GetTokenVersion"), query, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrUserNotFound
+	}
+	return version, err
+}
+
+// MarkEmailVerified verifies the user's email, provided it is still the
+// given one.
+func (r *userRepositoryImpl) MarkEmailVerified(ctx context.Context, id int, email string) error {
+	query := `
+        UPDATE users
+        SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND email = $2`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "UserRepository.MarkEmailVerified"), query, id, email)
 	if err != nil {
 		return err
 	}
@@ -142,7 +243,7 @@ func (r *userRepositoryImpl) UpdatePassword(ctx context.Context, email, password
 		return err
 	}
 	if rows == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"main/entity"
+	"main/money"
+	"main/tracing"
+)
+
+var ErrWalletNotFound = errors.New("wallet not found")
+
+type WalletRepository interface {
+	GetWalletByUserID(ctx context.Context, userID int) (*entity.Wallet, error)
+	GetWalletByNumber(ctx context.Context, walletNumber string) (*entity.Wallet, error)
+	GetWalletByIDForUpdate(ctx context.Context, id int) (*entity.Wallet, error)
+	UpdateBalance(ctx context.Context, id int, delta money.Amount) error
+}
+
+type walletRepositoryImpl struct {
+	db *sql.DB
+}
+
+func NewWalletRepository(db *sql.DB) WalletRepository {
+	return &walletRepositoryImpl{db: db}
+}
+
+const walletSelect = `
+        SELECT w.id, w.user_id, w.wallet_number, w.balance,
+               w.created_at, w.updated_at,
+               u.username, u.email
+        FROM wallets w
+        JOIN users u ON w.user_id = u.id`
+
+func (r *walletRepositoryImpl) GetWalletByUserID(ctx context.Context, userID int) (*entity.Wallet, error) {
+	return r.getWallet(tracing.WithQueryName(ctx, "WalletRepository.GetWalletByUserID"), walletSelect+` WHERE w.user_id = $1`, userID)
+}
+
+func (r *walletRepositoryImpl) GetWalletByNumber(ctx context.Context, walletNumber string) (*entity.Wallet, error) {
+	return r.getWallet(tracing.WithQueryName(ctx, "WalletRepository.GetWalletByNumber"), walletSelect+` WHERE w.wallet_number = $1`, walletNumber)
+}
+
+// GetWalletByIDForUpdate locks the wallet row until the surrounding
+// transaction ends. It must be called within Transactor.WithinTransaction.
+func (r *walletRepositoryImpl) GetWalletByIDForUpdate(ctx context.Context, id int) (*entity.Wallet, error) {
+	return r.getWallet(tracing.WithQueryName(ctx, "WalletRepository.GetWalletByIDForUpdate"), walletSelect+` WHERE w.id = $1 FOR UPDATE OF w`, id)
+}
+
+// The caller names the query in ctx with tracing.WithQueryName.
+func (r *walletRepositoryImpl) getWallet(ctx context.Context, query string, arg interface{}) (*entity.Wallet, error) {
+	wallet := &entity.Wallet{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, arg).Scan(
+		&wallet.ID,
+		&wallet.UserID,
+		&wallet.WalletNumber,
+		&wallet.Balance,
+		&wallet.CreatedAt,
+		&wallet.UpdatedAt,
+		&wallet.OwnerName,
+		&wallet.OwnerEmail,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func (r *walletRepositoryImpl) UpdateBalance(ctx context.Context, id int, delta money.Amount) error {
+	query := `
+        UPDATE wallets
+        SET balance = balance + $1,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2`
+
+	result, err := conn(ctx, r.db).ExecContext(tracing.WithQueryName(ctx, "WalletRepository.UpdateBalance"), query, delta, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrWalletNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetupContinuesRemoteTraceAndWritesFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(Config{Exporter: "file", File: file, ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	header := http.Header{}
+	header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	_, span := otel.Tracer("test").Start(ctx, "child")
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("child trace ID = %s, want %s", got, traceID)
+	}
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), traceID) || !strings.Contains(string(output), `"Name":"child"`) {
+		t.Fatalf("exported spans do not include the child span:\n%s", output)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(Config{Exporter: "zipkin"}); err == nil {
+		t.Fatal("Setup succeeded, want error")
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	ctx := context.Background()
+	if got := QueryName(ctx); got != "" {
+		t.Fatalf("QueryName of empty context = %q, want empty", got)
+	}
+
+	ctx = WithQueryName(ctx, "UserRepository.GetUserByID")
+	if got := QueryName(ctx); got != "UserRepository.GetUserByID" {
+		t.Fatalf("QueryName = %q, want UserRepository.GetUserByID", got)
+	}
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"main/logging"
+	"main/repository"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type JanitorConfig struct {
+	// IdempotencyKeyTTL is how long responses are kept for replay.
+	IdempotencyKeyTTL time.Duration
+	// ThrottleTTL is how long failed-attempt counters are kept after the
+	// last failure; it should not be shorter than the lockout reset window.
+	ThrottleTTL time.Duration
+}
+
+// JanitorService deletes rows that are no longer needed.
+type JanitorService interface {
+	PurgeExpired(ctx context.Context) error
+}
+
+type janitorService struct {
+	idempotencyRepo repository.IdempotencyRepository
+	tokenRepo       repository.TokenRepository
+	throttleRepo    repository.ThrottleRepository
+	config          JanitorConfig
+}
+
+func NewJanitorService(
+	idempotencyRepo repository.IdempotencyRepository,
+	tokenRepo repository.TokenRepository,
+	throttleRepo repository.ThrottleRepository,
+	config JanitorConfig,
+) JanitorService {
+	return &janitorService{
+		idempotencyRepo: idempotencyRepo,
+		tokenRepo:       tokenRepo,
+		throttleRepo:    throttleRepo,
+		config:          config,
+	}
+}
+
+func (s *janitorService) PurgeExpired(ctx context.Context) error {
+	now := time.Now()
+
+	idempotencyKeys, err := s.idempotencyRepo.DeleteIdempotencyKeysBefore(ctx, now.Add(-s.config.IdempotencyKeyTTL))
+	if err != nil {
+		return err
+	}
+	tokens, err := s.tokenRepo.DeleteExpiredTokens(ctx, now)
+	if err != nil {
+		return err
+	}
+	throttles, err := s.throttleRepo.DeleteStaleThrottles(ctx, now.Add(-s.config.ThrottleTTL))
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"idempotency_keys": idempotencyKeys,
+		"tokens":           tokens,
+		"throttles":        throttles,
+	}).Info("Purged expired rows")
+	return nil
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"main/dto"
+	"main/repository"
+)
+
+type LedgerService interface {
+	VerifyBooks(ctx context.Context) (*dto.LedgerReport, error)
+}
+
+type ledgerService struct {
+	repo repository.LedgerRepository
+}
+
+func NewLedgerService(repo repository.LedgerRepository) LedgerService {
+	return &ledgerService{repo: repo}
+}
+
+// VerifyBooks proves that all postings sum to zero and that every wallet's
+// stored balance matches the balance derived from the ledger.
+func (s *ledgerService) VerifyBooks(ctx context.Context) (*dto.LedgerReport, error) {
+	trialBalance, err := s.repo.GetTrialBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches, err := s.repo.ListWalletBalanceMismatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.LedgerReport{
+		TrialBalance: trialBalance,
+		Balanced:     trialBalance.IsZero() && len(mismatches) == 0,
+		Mismatches:   mismatches,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Business metrics, labelled with bounded values only. Volumes are counted
+// in minor currency units so they stay exact.
+var (
+	registrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ewallet_registrations_total",
+		Help: "Total number of users registered.",
+	})
+	loginsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ewallet_logins_total",
+		Help: "Total number of login attempts by step and result.",
+	}, []string{"step", "result"})
+	transfersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ewallet_transfers_total",
+		Help: "Total number of completed transfers.",
+	}, []string{"currency"})
+	transferVolumeTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ewallet_transfer_volume_minor_total",
+		Help: "Total amount transferred, in minor currency units.",
+	}, []string{"currency"})
+	topUpsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ewallet_top_ups_total",
+		Help: "Total number of completed top ups by source of fund.",
+	}, []string{"source_of_fund"})
+	topUpVolumeTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ewallet_top_up_volume_minor_total",
+		Help: "Total amount topped up, in minor currency units.",
+	}, []string{"source_of_fund", "currency"})
+)
+
+// Login results.
+const (
+	loginResultSucceeded = "succeeded"
+	loginResultFailed    = "failed"
+	loginResultLocked    = "locked"
+)
+
+// Login steps.
+const (
+	loginStepPassword  = "password"
+	loginStepTwoFactor = "two_factor"
+)
+
+// countLockedLogin counts a login refused because of a lockout.
+func countLockedLogin(step string, err error) {
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		loginsTotal.WithLabelValues(step, loginResultLocked).Inc()
+	}
+}
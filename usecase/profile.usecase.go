@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/dto"
+	"main/entity"
+	"main/mailer"
+	"main/repository"
+)
+
+type ProfileService interface {
+	GetProfile(ctx context.Context, userID int) (*dto.ProfileResponse, error)
+	UpdateProfile(ctx context.Context, userID int, req dto.UpdateProfileRequest) (*dto.ProfileResponse, error)
+}
+
+// VerificationSender sends email verification links; Service implements it.
+type VerificationSender interface {
+	ResendVerificationEmail(ctx context.Context, userID int) error
+}
+
+type profileService struct {
+	repo       repository.UserRepository
+	walletRepo repository.WalletRepository
+	mailer     mailer.Mailer
+	verifier   VerificationSender
+}
+
+func NewProfileService(
+	repo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	mail mailer.Mailer,
+	verifier VerificationSender,
+) ProfileService {
+	return &profileService{
+		repo:       repo,
+		walletRepo: walletRepo,
+		mailer:     mail,
+		verifier:   verifier,
+	}
+}
+
+func (s *profileService) GetProfile(ctx context.Context, userID int) (_ *dto.ProfileResponse, err error) {
+	ctx, span := tracer.Start(ctx, "ProfileService.GetProfile")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ProfileResponse{
+		User:         user,
+		WalletNumber: wallet.WalletNumber,
+		Balance:      wallet.Balance,
+	}, nil
+}
+
+// UpdateProfile replaces the user's profile fields. When the email changes
+// it must be verified again, and the previous address is notified so a
+// hijacked session cannot quietly take over the account's email.
+func (s *profileService) UpdateProfile(ctx context.Context, userID int, req dto.UpdateProfileRequest) (_ *dto.ProfileResponse, err error) {
+	ctx, span := tracer.Start(ctx, "ProfileService.UpdateProfile")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previousEmail := user.Email
+	emailChanged := req.Email != user.Email
+	if emailChanged {
+		existing, err := s.repo.GetUserByEmail(ctx, req.Email)
+		if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, err
+		}
+		if existing != nil && existing.ID != userID {
+			return nil, ErrEmailTaken
+		}
+	}
+
+	user.Username = req.Username
+	user.Email = req.Email
+	user.DisplayName = optionalString(req.DisplayName)
+	user.AvatarURL = optionalString(req.AvatarURL)
+	user.UpdatedAt = req.UpdatedAt
+
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		// The change is committed; a failed email must not report it as failed
+		if err := s.sendEmailChangeEmails(ctx, user, previousEmail); err != nil {
+			profile, profileErr := s.GetProfile(ctx, userID)
+			if profileErr != nil {
+				return nil, profileErr
+			}
+			return profile, fmt.Errorf("%w: %v", ErrVerificationEmailFailed, err)
+		}
+	}
+
+	return s.GetProfile(ctx, userID)
+}
+
+// sendEmailChangeEmails notifies the previous address of the change and sends
+// a verification link to the new one. Both are attempted even if one fails.
+func (s *profileService) sendEmailChangeEmails(ctx context.Context, user *entity.User, previousEmail string) error {
+	msg, err := mailer.NewMessage(previousEmail, mailer.TemplateEmailChanged, map[string]string{
+		"Username": user.Username,
+		"NewEmail": user.Email,
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+
+	return errors.Join(err, s.verifier.ResendVerificationEmail(ctx, user.ID))
+}
+
+// optionalString maps an empty string to NULL.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
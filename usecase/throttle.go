@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"main/entity"
+	"main/logging"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Throttle scopes. Failures are counted separately per account (keyed by
+// normalised email, whether or not it is registered) and per client IP.
+const (
+	throttleLoginAccount = "login_account"
+	throttleLoginIP      = "login_ip"
+	throttleResetAccount = "reset_account"
+	throttleResetIP      = "reset_ip"
+)
+
+// Actions recorded in the login attempt history.
+const (
+	attemptLogin          = "login"
+	attemptLoginTwoFactor = "login_2fa"
+	attemptResetPassword  = "reset_password"
+)
+
+// LockoutPolicy controls how repeated failures lock a key out.
+type LockoutPolicy struct {
+	// FreeAttempts is the number of failures allowed before any lockout.
+	FreeAttempts int
+	// BaseDelay is the first lockout; every further failure doubles it, up
+	// to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// ResetAfter forgets earlier failures after this long without a new one.
+	ResetAfter time.Duration
+}
+
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	excess := failures - p.FreeAttempts
+	if excess <= 0 {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < excess && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// LockedError is returned while an account or client is locked out.
+type LockedError struct {
+	UnlockAt time.Time
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed attempts, try again later"
+}
+
+type throttleKey struct {
+	scope  string
+	key    string
+	policy LockoutPolicy
+}
+
+func (s *service) loginThrottleKeys(email, ip string) []throttleKey {
+	return []throttleKey{
+		{scope: throttleLoginAccount, key: normalizeEmail(email), policy: s.config.AccountLockout},
+		{scope: throttleLoginIP, key: ip, policy: s.config.IPLockout},
+	}
+}
+
+func (s *service) resetThrottleKeys(email, ip string) []throttleKey {
+	return []throttleKey{
+		{scope: throttleResetAccount, key: normalizeEmail(email), policy: s.config.AccountLockout},
+		{scope: throttleResetIP, key: ip, policy: s.config.IPLockout},
+	}
+}
+
+// checkLocked returns a *LockedError if any of the keys is locked out.
+func (s *service) checkLocked(ctx context.Context, keys []throttleKey) error {
+	var locked *LockedError
+	for _, k := range keys {
+		throttle, err := s.throttleRepo.GetThrottle(ctx, k.scope, k.key)
+		if err != nil {
+			return err
+		}
+		if throttle.LockedUntil == nil || !throttle.LockedUntil.After(time.Now()) {
+			continue
+		}
+		if locked == nil || throttle.LockedUntil.After(locked.UnlockAt) {
+			locked = &LockedError{UnlockAt: *throttle.LockedUntil}
+		}
+	}
+
+	if locked != nil {
+		return locked
+	}
+	return nil
+}
+
+// recordFailure counts a failure against every key, locking out those that
+// have run out of free attempts for an exponentially growing period.
+func (s *service) recordFailure(ctx context.Context, keys []throttleKey) error {
+	for _, k := range keys {
+		throttle, err := s.throttleRepo.RecordFailure(ctx, k.scope, k.key, k.policy.ResetAfter)
+		if err != nil {
+			return err
+		}
+
+		if d := k.policy.delay(throttle.FailedCount); d > 0 {
+			if err := s.throttleRepo.LockThrottle(ctx, k.scope, k.key, time.Now().Add(d)); err != nil {
+				return err
+			}
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"scope":        k.scope,
+				"failed_count": throttle.FailedCount,
+				"locked_for":   d.String(),
+			}).Warn("Too many failed attempts, locking out")
+		}
+	}
+	return nil
+}
+
+// recordSuccess clears the account's failures. Per-IP failures are left to
+// expire, so an attacker cannot reset them by logging into their own account.
+func (s *service) recordSuccess(ctx context.Context, keys []throttleKey) error {
+	return s.throttleRepo.ResetThrottle(ctx, keys[0].scope, keys[0].key)
+}
+
+func (s *service) recordAttempt(ctx context.Context, user *entity.User, email, ip, action, reason string) error {
+	attempt := &entity.LoginAttempt{
+		Email:     normalizeEmail(email),
+		IPAddress: ip,
+		Action:    action,
+		Success:   reason == "",
+		Reason:    reason,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	return s.throttleRepo.CreateLoginAttempt(ctx, attempt)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyPassword spends as long as a real bcrypt comparison, so
+// response times do not reveal whether an email is registered.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// loginFailed records a failed login and returns the error for the client.
+func (s *service) loginFailed(ctx context.Context, keys []throttleKey, user *entity.User, email, ip, action, reason string) error {
+	if err := s.recordFailure(ctx, keys); err != nil {
+		return err
+	}
+	if err := s.recordAttempt(ctx, user, email, ip, action, reason); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// resetFailed records a failed password reset and returns the error for the
+// client.
+func (s *service) resetFailed(ctx context.Context, keys []throttleKey, user *entity.User, email, ip, reason string) error {
+	if err := s.recordFailure(ctx, keys); err != nil {
+		return err
+	}
+	if err := s.recordAttempt(ctx, user, email, ip, attemptResetPassword, reason); err != nil {
+		return err
+	}
+	return ErrInvalidResetCode
+}
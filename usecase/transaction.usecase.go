@@ -3,23 +3,51 @@ package usecase
 import (
 	"context"
 	"main/dto"
+	"main/entity"
 	"main/repository"
 	"math"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type TransactionService interface {
 	ListTransactions(ctx context.Context, userID int, req dto.TransactionListRequest) (*dto.TransactionListResponse, error)
+	GetTransaction(ctx context.Context, userID, id int) (*entity.Transaction, error)
+}
+
+type TransactionServiceConfig struct {
+	// DefaultPageSize applies when the request has no limit; larger limits
+	// are capped at MaxPageSize.
+	DefaultPageSize int
+	MaxPageSize     int
 }
 
 type transactionService struct {
-	repo repository.TransactionRepository
+	repo   repository.TransactionRepository
+	config TransactionServiceConfig
 }
 
-func NewTransactionService(repo repository.TransactionRepository) TransactionService {
-	return &transactionService{repo: repo}
+func NewTransactionService(repo repository.TransactionRepository, config TransactionServiceConfig) TransactionService {
+	return &transactionService{repo: repo, config: config}
 }
 
-func (s *transactionService) ListTransactions(ctx context.Context, userID int, req dto.TransactionListRequest) (*dto.TransactionListResponse, error) {
+func (s *transactionService) ListTransactions(ctx context.Context, userID int, req dto.TransactionListRequest) (_ *dto.TransactionListResponse, err error) {
+	ctx, span := tracer.Start(ctx, "TransactionService.ListTransactions")
+	defer func() { endSpan(span, err) }()
+
+	if req.Limit <= 0 {
+		req.Limit = s.config.DefaultPageSize
+	}
+	if req.Limit > s.config.MaxPageSize {
+		req.Limit = s.config.MaxPageSize
+	}
+	span.SetAttributes(
+		attribute.Int("page", req.Page),
+		attribute.Int("limit", req.Limit),
+		attribute.String("sort_by", req.SortBy),
+		attribute.Bool("filtered", req.Search != "" || req.StartDate != "" || req.EndDate != ""),
+	)
+
 	transactions, totalItems, err := s.repo.ListTransactions(ctx, userID, req)
 	if err != nil {
 		return nil, err
@@ -37,3 +65,10 @@ func (s *transactionService) ListTransactions(ctx context.Context, userID int, r
 		},
 	}, nil
 }
+
+func (s *transactionService) GetTransaction(ctx context.Context, userID, id int) (_ *entity.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "TransactionService.GetTransaction")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.GetTransactionByID(ctx, userID, id)
+}
@@ -0,0 +1,272 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"main/dto"
+	"main/entity"
+	"main/totp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+)
+
+// SetupTwoFactor generates a new TOTP secret for the user. It stays
+// inactive until confirmed with VerifyTwoFactor.
+func (s *service) SetupTwoFactor(ctx context.Context, userID int) (_ *dto.TwoFactorSetupResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.SetupTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(secret, s.config.AppName, user.Email),
+	}, nil
+}
+
+// VerifyTwoFactor activates two-factor authentication once the user proves
+// their authenticator works, and returns single-use recovery codes. Only
+// hashes of the codes are stored, so this is the only time they are shown.
+func (s *service) VerifyTwoFactor(ctx context.Context, userID int, req dto.TwoFactorCodeRequest) (_ *dto.RecoveryCodesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.VerifyTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	if err := s.checkTOTPThrottled(ctx, user, req.Code, req.IPAddress); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := totp.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = strings.ToLower(code[:5] + "-" + code[5:10])
+		hashes[i] = hashToken(codes[i])
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.twoFactorRepo.EnableTOTP(ctx, userID); err != nil {
+			return err
+		}
+		return s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *service) DisableTwoFactor(ctx context.Context, userID int, req dto.TwoFactorCodeRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.DisableTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := s.checkTOTPThrottled(ctx, user, req.Code, req.IPAddress); err != nil {
+		return err
+	}
+
+	return s.twoFactorRepo.DisableTOTP(ctx, userID)
+}
+
+// checkTOTPThrottled checks a code from a signed-in user against the same
+// counters as logins, so a stolen session cannot guess codes either.
+func (s *service) checkTOTPThrottled(ctx context.Context, user *entity.User, code, ip string) error {
+	keys := s.loginThrottleKeys(user.Email, ip)
+	if err := s.checkLocked(ctx, keys); err != nil {
+		return err
+	}
+
+	err := s.checkTOTP(ctx, user, code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		if err := s.recordFailure(ctx, keys); err != nil {
+			return err
+		}
+		return ErrInvalidTwoFactorCode
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.recordSuccess(ctx, keys)
+}
+
+// LoginTwoFactor completes a login started by Login for an account with
+// two-factor authentication, exchanging the challenge token and a TOTP or
+// recovery code for an access/refresh token pair.
+func (s *service) LoginTwoFactor(ctx context.Context, req dto.TwoFactorLoginRequest) (_ *dto.TokenResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.LoginTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	token, err := s.ValidateToken(req.ChallengeToken)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidChallenge
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != TokenTypeTwoFactorChallenge {
+		return nil, ErrInvalidChallenge
+	}
+	sub, _ := claims["sub"].(float64)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.repo.GetUserByID(ctx, int(sub))
+	if err != nil || user.TOTPEnabledAt == nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	// Codes are guessed against the same counters as passwords
+	keys := s.loginThrottleKeys(user.Email, req.IPAddress)
+	if err := s.checkLocked(ctx, keys); err != nil {
+		countLockedLogin(loginStepTwoFactor, err)
+		return nil, err
+	}
+
+	if req.Code != "" {
+		err = s.checkTOTP(ctx, user, req.Code)
+	} else {
+		err = s.checkRecoveryCode(ctx, user, req.RecoveryCode)
+	}
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		loginsTotal.WithLabelValues(loginStepTwoFactor, loginResultFailed).Inc()
+		if err := s.recordFailure(ctx, keys); err != nil {
+			return nil, err
+		}
+		if err := s.recordAttempt(ctx, user, user.Email, req.IPAddress, attemptLoginTwoFactor, "wrong code"); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recordSuccess(ctx, keys); err != nil {
+		return nil, err
+	}
+	if err := s.recordAttempt(ctx, user, user.Email, req.IPAddress, attemptLoginTwoFactor, ""); err != nil {
+		return nil, err
+	}
+
+	// Challenges are single use
+	if err := s.tokenRepo.RevokeAccessToken(ctx, jti, user.ID, time.Unix(int64(exp), 0)); err != nil {
+		return nil, err
+	}
+	loginsTotal.WithLabelValues(loginStepTwoFactor, loginResultSucceeded).Inc()
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, familyID)
+}
+
+// issueChallenge returns a short-lived token proving the password step of
+// a two-step login succeeded.
+func (s *service) issueChallenge(user *entity.User) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return s.keys.Sign(jwt.MapClaims{
+		"sub": user.ID,
+		"jti": jti,
+		"typ": TokenTypeTwoFactorChallenge,
+		"iat": now.Unix(),
+		"exp": now.Add(s.config.ChallengeDuration).Unix(),
+		"iss": s.config.JWTIssuer,
+	})
+}
+
+func (s *service) checkTOTP(ctx context.Context, user *entity.User, code string) error {
+	if user.TOTPSecret == nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	step, ok := totp.Validate(*user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.twoFactorRepo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *service) checkRecoveryCode(ctx context.Context, user *entity.User, code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(ctx, user.ID, hashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
@@ -3,47 +3,132 @@ package usecase
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"main/dto"
 	"main/entity"
+	"main/keyring"
+	"main/logging"
+	"main/mailer"
+	"main/password"
 	"main/repository"
+	"net/url"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrEmailTaken is the repository error, so a concurrent write that
+	// loses the race on the unique email reads the same as the pre-check.
+	ErrEmailTaken        = repository.ErrEmailTaken
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordUnchanged = errors.New("new password must differ from the current one")
+	// ErrInvalidCredentials and ErrInvalidResetCode are deliberately the same
+	// whether or not the email is registered.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidResetCode   = errors.New("invalid or expired reset code")
+)
+
+// Values of the "typ" claim, which keeps challenge tokens from being used
+// as access tokens.
+const (
+	TokenTypeAccess             = "access"
+	TokenTypeTwoFactorChallenge = "2fa_challenge"
+)
+
 type Service interface {
 	Register(ctx context.Context, req dto.RegisterRequest) (*entity.User, error)
-	Login(ctx context.Context, req dto.LoginRequest) (string, error)
-	ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) (string, error)
+	Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error)
+	LoginTwoFactor(ctx context.Context, req dto.TwoFactorLoginRequest) (*dto.TokenResponse, error)
+	Refresh(ctx context.Context, req dto.RefreshTokenRequest) (*dto.TokenResponse, error)
+	Logout(ctx context.Context, userID int, jti string, expiresAt time.Time, req dto.LogoutRequest) error
+	ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) error
 	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error
+	ChangePassword(ctx context.Context, userID int, req dto.ChangePasswordRequest) (*dto.TokenResponse, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	IsTokenVersionCurrent(ctx context.Context, userID, version int) (bool, error)
+	SetupTwoFactor(ctx context.Context, userID int) (*dto.TwoFactorSetupResponse, error)
+	VerifyTwoFactor(ctx context.Context, userID int, req dto.TwoFactorCodeRequest) (*dto.RecoveryCodesResponse, error)
+	DisableTwoFactor(ctx context.Context, userID int, req dto.TwoFactorCodeRequest) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userID int) error
+}
+
+type ServiceConfig struct {
+	JWTIssuer       string
+	JWTDuration     time.Duration
+	RefreshDuration time.Duration
+	// ChallengeDuration is how long a two-factor login challenge is valid.
+	ChallengeDuration time.Duration
+	// AppName is shown to users, e.g. in authenticator apps.
+	AppName string
+	// AppBaseURL is the front-end address used to build links in emails.
+	AppBaseURL string
+	// VerificationDuration is how long email verification links are valid.
+	VerificationDuration time.Duration
+	// ResetCodeDuration is how long password reset codes are valid.
+	ResetCodeDuration time.Duration
+	// AccountLockout and IPLockout throttle failed logins and password
+	// resets per email and per client IP respectively.
+	AccountLockout LockoutPolicy
+	IPLockout      LockoutPolicy
+	// PasswordPolicy applies to every new password.
+	PasswordPolicy password.Policy
 }
 
 type service struct {
-	repo        repository.UserRepository
-	jwtSecret   []byte
-	jwtIssuer   string
-	jwtDuration time.Duration
+	repo          repository.UserRepository
+	tokenRepo     repository.TokenRepository
+	twoFactorRepo repository.TwoFactorRepository
+	throttleRepo  repository.ThrottleRepository
+	transactor    repository.Transactor
+	keys          *keyring.Keyring
+	mailer        mailer.Mailer
+	config        ServiceConfig
 }
 
-func NewService(repo repository.UserRepository, jwtSecret string, jwtIssuer string, jwtDuration time.Duration) Service {
+func NewService(
+	repo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	twoFactorRepo repository.TwoFactorRepository,
+	throttleRepo repository.ThrottleRepository,
+	transactor repository.Transactor,
+	keys *keyring.Keyring,
+	mail mailer.Mailer,
+	config ServiceConfig,
+) Service {
 	return &service{
-		repo:        repo,
-		jwtSecret:   []byte(jwtSecret),
-		jwtIssuer:   jwtIssuer,
-		jwtDuration: jwtDuration,
+		repo:          repo,
+		tokenRepo:     tokenRepo,
+		twoFactorRepo: twoFactorRepo,
+		throttleRepo:  throttleRepo,
+		transactor:    transactor,
+		keys:          keys,
+		mailer:        mail,
+		config:        config,
 	}
 }
 
-func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (*entity.User, error) {
+func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (_ *entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	defer func() { endSpan(span, err) }()
+
 	// Check if user exists
 	existing, err := s.repo.GetUserByEmail(ctx, req.Email)
 	if err == nil && existing != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailTaken
+	}
+
+	if err := s.config.PasswordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
 	}
 
 	// Hash password
@@ -58,76 +143,262 @@ func (s *service) Register(ctx context.Context, req dto.RegisterRequest) (*entit
 		PasswordHash: string(hashedPassword),
 	}
 
-	err = s.repo.CreateUser(ctx, user)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.repo.CreateUser(ctx, user)
+	})
 	if err != nil {
 		return nil, err
 	}
+	registrationsTotal.Inc()
+	logging.FromContext(ctx).WithField("user_id", user.ID).Info("User registered")
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		return user, fmt.Errorf("%w: %v", ErrVerificationEmailFailed, err)
+	}
 
 	return user, nil
 }
 
-func (s *service) Login(ctx context.Context, req dto.LoginRequest) (string, error) {
+func (s *service) Login(ctx context.Context, req dto.LoginRequest) (_ *dto.LoginResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer func() { endSpan(span, err) }()
+
+	keys := s.loginThrottleKeys(req.Email, req.IPAddress)
+	if err := s.checkLocked(ctx, keys); err != nil {
+		countLockedLogin(loginStepPassword, err)
+		return nil, err
+	}
+
 	user, err := s.repo.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		return "", err
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	if user == nil {
+		compareDummyPassword(req.Password)
+		loginsTotal.WithLabelValues(loginStepPassword, loginResultFailed).Inc()
+		return nil, s.loginFailed(ctx, keys, nil, req.Email, req.IPAddress, attemptLogin, "unknown email")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		loginsTotal.WithLabelValues(loginStepPassword, loginResultFailed).Inc()
+		return nil, s.loginFailed(ctx, keys, user, req.Email, req.IPAddress, attemptLogin, "wrong password")
+	}
+	loginsTotal.WithLabelValues(loginStepPassword, loginResultSucceeded).Inc()
+
+	if err := s.recordSuccess(ctx, keys); err != nil {
+		return nil, err
+	}
+	if err := s.recordAttempt(ctx, user, req.Email, req.IPAddress, attemptLogin, ""); err != nil {
+		return nil, err
+	}
+
+	// Accounts with two-factor authentication get a challenge to complete
+	// at /login/2fa instead of tokens
+	if user.TOTPEnabledAt != nil {
+		challenge, err := s.issueChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(ctx, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.LoginResponse{TokenResponse: tokens}, nil
+}
+
+// Refresh rotates a refresh token: the presented token is consumed and a new
+// access/refresh pair in the same family is issued. Presenting a token that
+// was already consumed means it leaked, so the whole family is revoked.
+func (s *service) Refresh(ctx context.Context, req dto.RefreshTokenRequest) (_ *dto.TokenResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Refresh")
+	defer func() { endSpan(span, err) }()
+
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashToken(req.RefreshToken))
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil || stored.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	fresh, err := s.tokenRepo.MarkRefreshTokenUsed(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		logging.FromContext(ctx).WithField("user_id", stored.UserID).
+			Warn("Refresh token reused, revoking its family")
+		if err := s.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout denylists the presented access token and, when given, revokes the
+// refresh token family it belongs to.
+func (s *service) Logout(ctx context.Context, userID int, jti string, expiresAt time.Time, req dto.LogoutRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Logout")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.tokenRepo.RevokeAccessToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashToken(req.RefreshToken))
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) || (err == nil && stored.UserID != userID) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.tokenRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+func (s *service) issueTokens(ctx context.Context, user *entity.User, familyID string) (*dto.TokenResponse, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	now := time.Now()
+	accessToken, err := s.keys.Sign(jwt.MapClaims{
 		"sub": user.ID,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(s.jwtDuration).Unix(),
-		"iss": s.jwtIssuer,
+		"jti": jti,
+		"ver": user.TokenVersion,
+		"typ": TokenTypeAccess,
+		"iat": now.Unix(),
+		"exp": now.Add(s.config.JWTDuration).Unix(),
+		"iss": s.config.JWTIssuer,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	tokenString, err := token.SignedString(s.jwtSecret)
+	refreshToken, err := randomToken(32)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return tokenString, nil
+	err = s.tokenRepo.CreateRefreshToken(ctx, &entity.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(s.config.RefreshDuration),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.JWTDuration.Seconds()),
+	}, nil
 }
 
-func (s *service) ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) (string, error) {
+// ForgotPassword emails a reset link to the account owner. It succeeds
+// silently for unknown emails so callers cannot probe for accounts.
+func (s *service) ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ForgotPassword")
+	defer func() { endSpan(span, err) }()
+
 	user, err := s.repo.GetUserByEmail(ctx, req.Email)
-	fmt.Println(user)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	// Generate reset code
-	code := make([]byte, 32)
-	_, err = rand.Read(code)
+	// Generate reset code; only its hash is stored
+	resetCode, err := randomToken(32)
 	if err != nil {
-		return "", err
+		return err
 	}
-	resetCode := base64.URLEncoding.EncodeToString(code)
 
-	err = s.repo.UpdateResetPasswordCode(ctx, req.Email, resetCode)
+	expiresAt := time.Now().Add(s.config.ResetCodeDuration)
+	err = s.repo.UpdateResetPasswordCode(ctx, req.Email, hashToken(resetCode), expiresAt)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	resetURL := s.config.AppBaseURL + "/reset-password?" + url.Values{
+		"email": {user.Email},
+		"code":  {resetCode},
+	}.Encode()
+
+	msg, err := mailer.NewMessage(user.Email, mailer.TemplatePasswordReset, map[string]string{
+		"Username":  user.Username,
+		"ResetURL":  resetURL,
+		"ExpiresIn": formatDuration(s.config.ResetCodeDuration),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return err
 	}
 
-	return resetCode, nil
+	logging.FromContext(ctx).WithField("user_id", user.ID).Info("Password reset email sent")
+	return nil
 }
 
-func (s *service) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+func (s *service) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ResetPassword")
+	defer func() { endSpan(span, err) }()
+
+	keys := s.resetThrottleKeys(req.Email, req.IPAddress)
+	if err := s.checkLocked(ctx, keys); err != nil {
+		return err
+	}
+
 	user, err := s.repo.GetUserByEmail(ctx, req.Email)
-	if err != nil {
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
 		return err
 	}
 
-	if user.ResetPasswordCode == nil || *user.ResetPasswordCode != req.ResetCode {
-		return errors.New("invalid reset code")
+	if user == nil || user.ResetPasswordCode == nil ||
+		subtle.ConstantTimeCompare([]byte(*user.ResetPasswordCode), []byte(hashToken(req.ResetCode))) != 1 {
+		return s.resetFailed(ctx, keys, user, req.Email, req.IPAddress, "wrong code")
 	}
 
 	if user.ResetPasswordExpiry == nil || user.ResetPasswordExpiry.Before(time.Now()) {
-		return errors.New("reset code expired")
+		return s.resetFailed(ctx, keys, user, req.Email, req.IPAddress, "expired code")
+	}
+
+	if err := s.config.PasswordPolicy.Validate(req.NewPassword, user.Username, user.Email); err != nil {
+		return err
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
@@ -135,14 +406,138 @@ func (s *service) ResetPassword(ctx context.Context, req dto.ResetPasswordReques
 		return err
 	}
 
-	return s.repo.UpdatePassword(ctx, req.Email, string(hashedPassword))
+	if err := s.replacePassword(ctx, user, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.recordSuccess(ctx, keys); err != nil {
+		return err
+	}
+	return s.recordAttempt(ctx, user, req.Email, req.IPAddress, attemptResetPassword, "")
 }
 
-func (s *service) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// ChangePassword replaces the password of a signed-in user, signing out all
+// of their sessions. A fresh token pair is returned for the current client.
+func (s *service) ChangePassword(ctx context.Context, userID int, req dto.ChangePasswordRequest) (_ *dto.TokenResponse, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stolen session must not be able to guess the current password
+	keys := s.loginThrottleKeys(user.Email, req.IPAddress)
+	if err := s.checkLocked(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		if err := s.recordFailure(ctx, keys); err != nil {
+			return nil, err
+		}
+		return nil, ErrIncorrectPassword
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		return nil, ErrPasswordUnchanged
+	}
+
+	if err := s.config.PasswordPolicy.Validate(req.NewPassword, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.replacePassword(ctx, user, string(hashedPassword)); err != nil {
+		return nil, err
+	}
+
+	user, err = s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, familyID)
+}
+
+// replacePassword stores the new password hash and revokes every session:
+// access tokens through the token version bump, refresh tokens directly.
+func (s *service) replacePassword(ctx context.Context, user *entity.User, passwordHash string) error {
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdatePassword(ctx, user.Email, passwordHash); err != nil {
+			return err
 		}
-		return s.jwtSecret, nil
+		return s.tokenRepo.RevokeUserRefreshTokens(ctx, user.ID)
 	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).WithField("user_id", user.ID).Info("Password replaced, sessions revoked")
+	return nil
+}
+
+func (s *service) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, s.keys.Keyfunc)
+}
+
+func (s *service) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.tokenRepo.IsAccessTokenRevoked(ctx, jti)
+}
+
+// IsTokenVersionCurrent reports whether an access token issued at version is
+// still valid, i.e. the password has not changed since.
+func (s *service) IsTokenVersionCurrent(ctx context.Context, userID, version int) (bool, error) {
+	current, err := s.repo.GetTokenVersion(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return current == version, nil
+}
+
+// randomToken returns n random bytes, URL-safe base64 encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is used to store bearer secrets such as refresh tokens, which
+// are already high-entropy and so need no salt or slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatDuration renders whole minutes, hours or days for emails, e.g.
+// "15 minutes" or "1 day".
+func formatDuration(d time.Duration) string {
+	unit, size := "minute", time.Minute
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		unit, size = "day", 24*time.Hour
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, size = "hour", time.Hour
+	}
+
+	n := int(d / size)
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
 }
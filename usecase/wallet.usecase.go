@@ -0,0 +1,327 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/dto"
+	"main/entity"
+	"main/funding"
+	"main/ledger"
+	"main/logging"
+	"main/repository"
+)
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be greater than zero")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrSelfTransfer        = errors.New("cannot transfer to your own wallet")
+	ErrRecipientNotFound   = errors.New("recipient wallet not found")
+	ErrAmountOutOfRange    = errors.New("amount is out of range")
+	ErrEmailNotVerified    = errors.New("email must be verified before moving money")
+)
+
+type WalletService interface {
+	GetWalletDetails(ctx context.Context, userID int) (*entity.Wallet, error)
+	Transfer(ctx context.Context, userID int, req dto.TransferRequest) (*entity.Transaction, error)
+	TopUp(ctx context.Context, userID int, req dto.TopUpRequest) (*entity.Transaction, error)
+	ListSourcesOfFund(ctx context.Context) ([]entity.SourceOfFund, error)
+}
+
+type WalletServiceConfig struct {
+	// RequireVerifiedEmail blocks transfers and top ups until the user has
+	// verified their email.
+	RequireVerifiedEmail bool
+}
+
+type walletService struct {
+	repo             repository.WalletRepository
+	userRepo         repository.UserRepository
+	transactionRepo  repository.TransactionRepository
+	sourceOfFundRepo repository.SourceOfFundRepository
+	ledgerRepo       repository.LedgerRepository
+	transactor       repository.Transactor
+	fundingSources   *funding.Registry
+	config           WalletServiceConfig
+}
+
+func NewWalletService(
+	repo repository.WalletRepository,
+	userRepo repository.UserRepository,
+	transactionRepo repository.TransactionRepository,
+	sourceOfFundRepo repository.SourceOfFundRepository,
+	ledgerRepo repository.LedgerRepository,
+	transactor repository.Transactor,
+	fundingSources *funding.Registry,
+	config WalletServiceConfig,
+) WalletService {
+	return &walletService{
+		repo:             repo,
+		userRepo:         userRepo,
+		transactionRepo:  transactionRepo,
+		sourceOfFundRepo: sourceOfFundRepo,
+		ledgerRepo:       ledgerRepo,
+		transactor:       transactor,
+		fundingSources:   fundingSources,
+		config:           config,
+	}
+}
+
+func (s *walletService) GetWalletDetails(ctx context.Context, userID int) (_ *entity.Wallet, err error) {
+	ctx, span := tracer.Start(ctx, "WalletService.GetWalletDetails")
+	defer func() { endSpan(span, err) }()
+
+	return s.repo.GetWalletByUserID(ctx, userID)
+}
+
+func (s *walletService) Transfer(ctx context.Context, userID int, req dto.TransferRequest) (_ *entity.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "WalletService.Transfer")
+	defer func() { endSpan(span, err) }()
+
+	if !req.Amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	if err := s.checkVerified(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	sender, err := s.repo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := s.repo.GetWalletByNumber(ctx, req.ToWalletNumber)
+	if errors.Is(err, repository.ErrWalletNotFound) {
+		return nil, ErrRecipientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sender.ID == recipient.ID {
+		return nil, ErrSelfTransfer
+	}
+
+	var transaction *entity.Transaction
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		locked, err := s.lockWallets(ctx, sender.ID, recipient.ID)
+		if err != nil {
+			return err
+		}
+		from, to := locked[sender.ID], locked[recipient.ID]
+
+		if from.Balance.LessThan(req.Amount) {
+			return ErrInsufficientBalance
+		}
+
+		if err := s.repo.UpdateBalance(ctx, from.ID, req.Amount.Neg()); err != nil {
+			return err
+		}
+		if err := s.repo.UpdateBalance(ctx, to.ID, req.Amount); err != nil {
+			return err
+		}
+
+		transaction = &entity.Transaction{
+			FromWalletID:    &from.ID,
+			ToWalletID:      to.ID,
+			Amount:          req.Amount,
+			Description:     req.Description,
+			TransactionType: entity.TransactionTypeTransfer,
+		}
+		if err := s.transactionRepo.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+
+		fromAccount, err := s.ledgerRepo.GetWalletAccount(ctx, from)
+		if err != nil {
+			return err
+		}
+		toAccount, err := s.ledgerRepo.GetWalletAccount(ctx, to)
+		if err != nil {
+			return err
+		}
+		entry := ledger.Transfer(transaction.ID, fromAccount, toAccount, req.Amount, "Transfer to "+to.WalletNumber)
+		if err := s.postJournalEntry(ctx, entry); err != nil {
+			return err
+		}
+
+		transaction.FromWalletNumber = from.WalletNumber
+		transaction.ToWalletNumber = to.WalletNumber
+		transaction.RecipientName = to.OwnerName
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).WithField("transaction_id", transaction.ID).Info("Transfer completed")
+
+	currency := req.Amount.Currency().String()
+	transfersTotal.WithLabelValues(currency).Inc()
+	transferVolumeTotal.WithLabelValues(currency).Add(float64(req.Amount.Minor()))
+
+	return transaction, nil
+}
+
+func (s *walletService) TopUp(ctx context.Context, userID int, req dto.TopUpRequest) (_ *entity.Transaction, err error) {
+	ctx, span := tracer.Start(ctx, "WalletService.TopUp")
+	defer func() { endSpan(span, err) }()
+
+	if !req.Amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	if err := s.checkVerified(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	source, err := s.sourceOfFundRepo.GetSourceOfFundByID(ctx, req.SourceOfFundID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Amount.LessThan(source.MinAmount) || req.Amount.GreaterThan(source.MaxAmount) {
+		return nil, fmt.Errorf("%w: %s top ups must be between %s and %s",
+			ErrAmountOutOfRange, source.Name, source.MinAmount, source.MaxAmount)
+	}
+
+	provider, err := s.fundingSources.Get(source.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	owned, err := s.repo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Charge outside the database transaction so no row lock is held across
+	// the call to the provider
+	reference, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	err = provider.Charge(ctx, funding.Charge{
+		Reference:    reference,
+		UserID:       userID,
+		WalletNumber: owned.WalletNumber,
+		Amount:       req.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction *entity.Transaction
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		wallet, err := s.repo.GetWalletByIDForUpdate(ctx, owned.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateBalance(ctx, wallet.ID, req.Amount); err != nil {
+			return err
+		}
+
+		transaction = &entity.Transaction{
+			ToWalletID:      wallet.ID,
+			Amount:          req.Amount,
+			Description:     "Top Up from " + source.Name,
+			SourceOfFundID:  &source.ID,
+			TransactionType: entity.TransactionTypeTopUp,
+			ChargeReference: &reference,
+		}
+		if err := s.transactionRepo.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+
+		floatAccount, err := s.ledgerRepo.GetAccountByCode(ctx, entity.LedgerAccountSystemFloat)
+		if err != nil {
+			return err
+		}
+		walletAccount, err := s.ledgerRepo.GetWalletAccount(ctx, wallet)
+		if err != nil {
+			return err
+		}
+		entry := ledger.TopUp(transaction.ID, floatAccount, walletAccount, req.Amount, transaction.Description)
+		if err := s.postJournalEntry(ctx, entry); err != nil {
+			return err
+		}
+
+		transaction.ToWalletNumber = wallet.WalletNumber
+		transaction.RecipientName = wallet.OwnerName
+		return nil
+	})
+	if err != nil {
+		return nil, s.refundCharge(ctx, provider, reference, err)
+	}
+
+	logging.FromContext(ctx).WithField("transaction_id", transaction.ID).Info("Top up completed")
+
+	topUpsTotal.WithLabelValues(source.Code).Inc()
+	topUpVolumeTotal.WithLabelValues(source.Code, req.Amount.Currency().String()).Add(float64(req.Amount.Minor()))
+
+	return transaction, nil
+}
+
+func (s *walletService) ListSourcesOfFund(ctx context.Context) (_ []entity.SourceOfFund, err error) {
+	ctx, span := tracer.Start(ctx, "WalletService.ListSourcesOfFund")
+	defer func() { endSpan(span, err) }()
+
+	return s.sourceOfFundRepo.ListSourcesOfFund(ctx)
+}
+
+// refundCharge gives back a charge whose top up could not be credited, and
+// returns the error that stopped the top up.
+func (s *walletService) refundCharge(ctx context.Context, provider funding.FundingSource, reference string, cause error) error {
+	// Refund even if the client has gone away
+	if err := provider.Refund(context.WithoutCancel(ctx), reference); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("charge_reference", reference).
+			Error("Failed to refund charge of failed top up")
+		return fmt.Errorf("%w (refund of charge %s failed: %v)", cause, reference, err)
+	}
+	return cause
+}
+
+func (s *walletService) checkVerified(ctx context.Context, userID int) error {
+	if !s.config.RequireVerifiedEmail {
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// postJournalEntry records a balanced journal entry alongside the balance
+// updates; it must run in the same database transaction as they do.
+func (s *walletService) postJournalEntry(ctx context.Context, entry *entity.JournalEntry) error {
+	if err := ledger.Validate(entry); err != nil {
+		return err
+	}
+	return s.ledgerRepo.CreateJournalEntry(ctx, entry)
+}
+
+// lockWallets locks the given wallets in ascending ID order, so two opposite
+// transfers between the same wallets can never deadlock each other.
+func (s *walletService) lockWallets(ctx context.Context, a, b int) (map[int]*entity.Wallet, error) {
+	if a > b {
+		a, b = b, a
+	}
+
+	locked := make(map[int]*entity.Wallet, 2)
+	for _, id := range []int{a, b} {
+		wallet, err := s.repo.GetWalletByIDForUpdate(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		locked[id] = wallet
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,153 @@
+// Package worker runs the application's periodic background tasks.
+package worker
+
+import (
+	"context"
+	"main/logging"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Task is one run of a periodic job. It should return promptly once ctx is
+// cancelled.
+type Task func(ctx context.Context) error
+
+// Status reports on one worker, for health checks.
+type Status struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	Interval  string     `json:"interval"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type worker struct {
+	name     string
+	interval time.Duration
+	task     Task
+
+	mu        sync.Mutex
+	running   bool
+	lastRun   *time.Time
+	lastError string
+}
+
+// Group starts a set of workers together and stops them together.
+type Group struct {
+	logger  logrus.FieldLogger
+	workers []*worker
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewGroup(logger logrus.FieldLogger) *Group {
+	return &Group{logger: logger}
+}
+
+// Add registers a task to run every interval, starting immediately. It must
+// be called before Start.
+func (g *Group) Add(name string, interval time.Duration, task Task) {
+	g.workers = append(g.workers, &worker{name: name, interval: interval, task: task})
+}
+
+// Start runs every worker in its own goroutine until Stop is called or ctx
+// is cancelled.
+func (g *Group) Start(ctx context.Context) {
+	ctx, g.cancel = context.WithCancel(ctx)
+	for _, w := range g.workers {
+		w.setRunning(true)
+		g.wg.Add(1)
+		go func(w *worker) {
+			defer g.wg.Done()
+			defer w.setRunning(false)
+			g.run(ctx, w)
+		}(w)
+	}
+}
+
+// Stop cancels the workers and waits for running tasks to return, or for
+// ctx to expire.
+func (g *Group) Stop(ctx context.Context) error {
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *Group) Statuses() []Status {
+	statuses := make([]Status, len(g.workers))
+	for i, w := range g.workers {
+		statuses[i] = w.status()
+	}
+	return statuses
+}
+
+func (g *Group) run(ctx context.Context, w *worker) {
+	logger := g.logger.WithField("worker", w.name)
+	ctx = logging.NewContext(ctx, logger)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		err := w.task(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		w.record(err)
+		if err != nil {
+			logger.WithError(err).Error("Background task failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *worker) setRunning(running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running = running
+}
+
+func (w *worker) record(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.lastRun = &now
+	w.lastError = ""
+	if err != nil {
+		w.lastError = err.Error()
+	}
+}
+
+func (w *worker) status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return Status{
+		Name:      w.name,
+		Running:   w.running,
+		Interval:  w.interval.String(),
+		LastRun:   w.lastRun,
+		LastError: w.lastError,
+	}
+}